@@ -6,9 +6,401 @@
 bmpinspect is a command-line utility that displays the contents of a
 Windows BMP image file.
 
+A file that starts with a RIFF header is treated as a RIFF container: its
+chunks are listed, and a "DIBs" or "DIBS" chunk is inspected as a BMP image
+without a fileheader.
+
+An OS/2 Bitmap Array ("BA") file is a chain of headers, each followed by an
+embedded image. The headers are listed, and then each image is inspected.
+Icon and pointer images (types "IC", "PT", "CI", and "CP") are inspected
+like other images; for "CI" and "CP", the color image that follows the mask
+is also inspected.
+
 Usage:
 
-    bmpinspect <bmp-file.bmp>
+    bmpinspect [options] <bmp-file.bmp> [<bmp-file.bmp>...]
+    bmpinspect <subcommand> [subcommand-options] <arguments>
+
+Subcommands:
+
+    inspect [options] <bmp-file.bmp> [<bmp-file.bmp>...]
+        Display the contents of the file. This is the default, if the first
+        argument is not a subcommand name. The options are listed below.
+        If more than one file is given, each one is preceded by a
+        "=== <filename> ===" line, and followed by a blank line. A file that
+        can't be inspected doesn't stop the others from being inspected,
+        but the exit status is nonzero. Options that write a single JSON
+        object or output file (-format=json, -generate-c-struct with
+        -output, -dump-palette, -dump-palette-css) can only be used with
+        one file.
+
+    validate <bmp-file.bmp>
+        Print only the warnings, and the validity score (see -validate). The
+        exit status is 0 for PASS, 1 for WARN, and 2 for FAIL.
+
+    dump [-format=raw|ppm] <bmp-file.bmp> [<output-file>]
+        Write the pixels of an uncompressed image as 8-bit RGB samples, top
+        row first, to the output file or to stdout. The "ppm" format (the
+        default) adds a PPM header; "raw" does not.
+
+    compare <bmp-file-1.bmp> <bmp-file-2.bmp>
+        Print the differences between two files: in header fields and palette
+        contents, and, for uncompressed images with the same dimensions, in
+        pixel colors.
+
+    create -width=W -height=H [-bpp=N] <output-file.bmp>
+        Create a BMP file. Same as the -create-bmp option. The default
+        bit depth is 24.
+
+Options:
+
+    -spec-refs
+        After each header field, show a reference to the structure and field
+        name used by the documentation (MSDN, or the OS/2 reference).
+
+    -raw-bytes
+        After each infoheader field, show the bytes that its value was
+        decoded from, in file order (little-endian).
+
+    -show-raw-infoheader
+        At the start of the infoheader section, before any fields are
+        parsed, print the bytes of the infoheader in hex, in file order,
+        with "|" between the fields. This works even if the header is too
+        damaged to be parsed.
+
+    -row-checksums
+        At the end of each row of pixels, print the CRC-32 of the row's bytes
+        (including padding). For RLE-compressed images, the checksum covers
+        the decoded pixels, one byte per pixel (three for RLE24), so that
+        differently-encoded copies of an image can be compared. Pixels
+        skipped by a DELTA code are not included.
+
+    -row-checksums-only
+        Like -row-checksums, but don't print the pixel values.
+
+    -row-map
+        Before the pixels, print a table mapping each row as stored in the
+        file ("physical row") to its position in the image ("logical row"),
+        and to its file offset.
+
+    -annotate-rle
+        At the end of each row of an RLE-compressed image, print the number
+        of pixels encoded in the row, and the expected number if different.
+
+    -decode-rle[=MODE]
+        For RLE-compressed images, print each pixel of the compressed and
+        uncompressed runs. MODE is one of:
+          replace: (the default) Print the pixels in the same format as for
+            an uncompressed image, instead of the run notation (such as
+            "5{67}" or "u3{01 02 03}"). The control codes (EOL, EOBMP,
+            DELTA) are still shown.
+          inline: Print the run notation, and after each run, the pixels it
+            decodes to, in brackets: "5{67} [67 67 67 67 67]" for RLE8,
+            "5{67} [6 7 6 7 6]" for RLE4. For RLE24, the pixels are RGB
+            triples. Lines longer than 120 characters are wrapped.
+
+    -rle-analysis
+        For RLE-compressed images, after the pixels, print how the compressed
+        bytes are divided among compressed runs, uncompressed runs, and
+        control codes (EOL, EOBMP, DELTA); a histogram of the lengths of the
+        compressed runs; the average length of the uncompressed runs, and the
+        size of their 2-byte headers relative to their contents; and the size
+        the data would have if adjacent same-color runs were merged.
+
+    -missing-eobmp-ok
+        Don't report RLE-compressed data that ends without an EOBMP code,
+        which some encoders omit. If the number of rows decoded is wrong,
+        that is still reported.
+
+    -check-unused-bits
+        For 16- and 32-bit images without a BITFIELDS definition, count the
+        pixels in which the unused bits (bit 15, or bits 31-24) are not 0.
+
+    -interpret-as-format=FORMAT
+        Display the pixels of an uncompressed image as if they were in the
+        given format, instead of the format given by the header, for files
+        whose header does not match the pixel data. The header fields are
+        displayed as usual. FORMAT is one of: 1bpp, 4bpp, 8bpp, 16bpp-555,
+        16bpp-565, 24bpp, 32bpp-rgb, 32bpp-argb (stored in B,G,R,A order),
+        or 32bpp-bgra (stored in A,R,G,B order).
+
+    -pixel-offsets
+        Before each pixel, print the file offset of its bytes, as "@0x36:".
+        For pixels smaller than a byte, the position of the pixel's lowest
+        bit is appended, as "@0x36.4:" (bit 0 is the least significant).
+        For RLE-compressed images, the offset of each run is printed.
+
+    -show-palette-rgb
+        For uncompressed images with a palette, print each pixel as its
+        palette index followed by the color it maps to, as "0f=#123456".
+        Because this makes the rows very long, it is only done for images
+        that are at most 64 pixels wide, unless -no-width-limit is also
+        given.
+
+    -no-width-limit
+        Use -show-palette-rgb even for images wider than 64 pixels.
+
+    -decode-pixels
+        For 16- and 32-bit images, print each pixel's R, G, and B components
+        after its value, scaled to the range 00-ff, as
+        "7c1f(R=ff,G=00,B=ff)". If there is an alpha mask, the A component
+        is also printed. The masks in the header are used for BI_BITFIELDS
+        and BI_ALPHABITFIELDS images. Otherwise, 16-bit images use the 5-5-5
+        layout, and 32-bit images use bits 23-16 for red, 15-8 for green,
+        and 7-0 for blue (bits 31-24 are ignored).
+
+    -check-srgb
+        For v4 and v5 BMPs, check that the endpoint and gamma fields are
+        consistent with the CSType field. For LCS_sRGB and
+        LCS_WINDOWS_COLOR_SPACE, they are ignored, and should all be 0. For
+        LCS_CALIBRATED_RGB, none of them should be 0.
+
+    -alpha-check
+        For 32-bit images with an alpha mask, examine the alpha channel, and
+        report whether it is fully opaque, fully transparent, binary (0 or
+        the maximum value only), or uses the full range. Also report the
+        number of pixels in which a color sample is larger than the alpha
+        sample, which means the colors are not premultiplied by alpha.
+
+    -complexity
+        For uncompressed images, print some measures of the image's
+        complexity: the variance of the pixel values, the number of unique
+        pixel values as a percentage of the number of pixels, the average
+        number of runs of same-valued pixels per row (as RLE compression
+        would use), and the percentage of horizontally adjacent pixels that
+        differ. For images with a palette, the palette indices are measured,
+        not the colors.
+
+    -pixel-uniqueness
+        For uncompressed images, print the number of pixels whose value
+        appears nowhere else in the image, and the number whose value is
+        repeated. Photographs usually have many unique pixels, and icons and
+        diagrams few. As with -complexity, images with a palette are measured
+        by their palette indices. Images with more than a million pixels are
+        sampled, by measuring every 8th pixel.
+
+    -lzw-estimate
+        For uncompressed images, estimate how small the pixels would be if
+        they were compressed with LZW, as GIF does, by running an LZW
+        encoder with 12-bit codes, and print the size as a percentage of
+        the uncompressed size. Indexed images are measured as one byte per
+        pixel. For other images, each byte is replaced by its difference from
+        the previous pixel's byte (like PNG's "Sub" filter) first.
+
+    -detect-duplicate-rows
+        For uncompressed images, count the rows that are identical to the
+        previous row in the file (including padding), and list each run of
+        identical rows, with the number of bytes that repeat earlier rows.
+
+    -find-pixel=RRGGBB
+        For uncompressed images, count the pixels whose color is RRGGBB (in
+        hex), and print the locations of the first 20 of them, as x and row
+        number. For images with a palette, the palette entry that matches the
+        color, or is closest to it, is also printed. For BITFIELDS images,
+        the color is the one decoded from the bitfields masks.
+
+    -diff-from-defaults
+        Only show the header fields whose value differs from the value that
+        is standard or implied by the specification (for example, biPlanes=1,
+        biClrImportant=0, bfReserved1=0). Fields that have no default value,
+        such as biWidth, are always shown, as are section headings, notes,
+        and warnings. The pixels are not shown.
+
+    -check-conventions
+        Compare the palette to the palette conventionally used by Windows,
+        and report the first entry that differs. For 1-bit images, index 0
+        should be black, and 1 white. For 4-bit images with 16 colors, the
+        palette should be the standard 16-color VGA palette. For 8-bit images
+        with 256 colors, the first 10 and last 10 entries should be the
+        static colors of the Windows system palette.
+
+    -palette-preview
+        After the color table, show each color as a block of 8 spaces with
+        that background color, 4 entries per line, followed by the color's
+        hex value. This uses ANSI escape codes, even if the output is not a
+        terminal. If the COLORTERM environment variable is "truecolor" or
+        "24bit", the exact colors are used; otherwise, the nearest colors in
+        the xterm 256-color palette are used.
+
+    -palette-sort=ORDER
+        After the color table, print the palette again, sorted by ORDER,
+        from lowest to highest, with each entry's index and color, and the
+        value it was sorted by. ORDER can be "luminance" (perceived
+        brightness, 0.299R + 0.587G + 0.114B, from 0 to 1), "hue" (0 to 360
+        degrees), or "saturation" (as in HSV, from 0 to 1). The default,
+        "index", means the palette is printed only once, in the usual order.
+
+    -color, -no-color
+        Use, or don't use, ANSI colors to highlight the bits of each BITFIELDS
+        mask. The default is to use colors if the output is a terminal.
+
+    -skip-section=NAME
+        Don't display the named section. NAME is one of: fileheader,
+        infoheader, bitfields, colortable, bits, profile. May be used more
+        than once. The fileheader is still read, since it is needed to
+        inspect the rest of the file. Skipping the infoheader skips
+        everything after it.
+
+    -section=NAME
+        Display only the named section, where NAME is one of the names used
+        by -skip-section. Nothing from the other sections is printed,
+        including warnings.
+
+    -table-headers
+        Display the fields of the fileheader and infoheader as a two-column
+        table, without file offsets. Notes and warnings about the fields go
+        in the Value column. The table uses box-drawing characters if the
+        output is a terminal.
+
+    -inspect-thumbnail
+        Some BMP files may store the position of a small thumbnail image in
+        the bfReserved1 and bfReserved2 fields. If those fields look like
+        they point to an infoheader, bmpinspect reports it. With this
+        option, it also inspects the thumbnail image.
+
+    -xmp
+        Look for an XMP metadata packet in the unused bytes between the
+        headers and the bitmap bits, where some Adobe applications put it.
+        If found, print its length and header, and the values of some
+        common properties, such as dc:creator and xmp:CreateDate.
+
+    -show-gaps
+        For each block of unused bytes, print its offset range, a hex dump of
+        up to 64 bytes, and a guess at what it contains. At the end, print
+        the total size of the unused bytes, with a warning if they make up
+        more than 5% of the file.
+
+    -statistics
+        At the end, print statistics about the image: its size, storage
+        efficiency, luminance range, most common color, and entropy. For
+        images with a palette, also print the number of palette entries
+        used, and the most used ones. For 24-bit images, also print the mean
+        and standard deviation of each channel. Statistics that depend on
+        the pixel values are only available for uncompressed images.
+
+    -no-status
+        Normally, if stderr is a terminal but the output is not, bmpinspect
+        shows the section it is parsing (e.g. "[Parsing INFOHEADER...]") on
+        a status line on stderr. This option turns that off.
+
+    -count-rows
+        Instead of the usual output, print just the number of rows encoded
+        in the image, and whether it matches the height field. For RLE-
+        compressed images, rows skipped by DELTA codes are counted.
+
+    -describe
+        Instead of the usual output, print a one-sentence description of the
+        image: its orientation, dimensions, bit depth, BMP version, and size,
+        and, if available, its compression ratio, palette size, and
+        resolution.
+
+    -format=FORMAT
+        The output format: "text" (the default), or "json". With "json", the
+        usual output is replaced by a JSON object with these members: file,
+        fileSize, version, versionName; fileHeader, infoHeader, and bitfields
+        (lists of fields, each with its name, file offset, raw value, and
+        the annotation that would have been printed after the name); other
+        (fields from other sections); palette (colors as "#rrggbb");
+        pixelData (each row's pixels in hex, without padding; for RLE, the
+        decoded pixels); profile; compressionStats; warnings; and, if the
+        file could not be read, error.
+
+    -check-encoder
+        At the end, guess which application wrote the file, from quirks that
+        some encoders are known to leave: an ICC profile in the gap before
+        the bits, or 2 extra bytes after the bits (Adobe Photoshop); sRGB
+        with the endpoints filled in (ImageMagick); a cursor hotspot in the
+        bfReserved fields (conversion from a Windows cursor). These are only
+        heuristics. If more than one matches, they are all listed.
+
+    -format-check=PROFILE
+        At the end, check the file against a profile: the subset of the BMP
+        format that some kind of software supports. Each rule that the file
+        breaks is printed as a "Violation:" line. The profiles are:
+        "windows-gdi" (no OS/2 formats; BitCount 1, 4, 8, 16, 24, or 32; no
+        compression other than RLE4, RLE8, and BITFIELDS), "gdi-dib" (as
+        windows-gdi, and biClrUsed must be set for images with a palette),
+        "printer-driver" (as windows-gdi, but JPEG and PNG are allowed),
+        "windows-ce" (2-bit images, ALPHABITFIELDS, and BI_SRCPREROTATE are
+        allowed, but RLE is not), "os2" (the file must use an OS/2 header),
+        and "strict-spec" (as gdi-dib, and the reserved fields must be 0,
+        bfSize and biSizeImage must be correct, there must be no unused bytes
+        before the bits, and there must be no warnings).
+
+    -color-profile-type
+        Instead of the usual output, print just a one-line summary of the
+        embedded ICC color profile (version, device class, color space, and
+        size).
+
+    -validate
+        At the end, print a validity score from 0 to 100. Points are deducted
+        for each problem found, up to a maximum for each category of problem.
+        A score of 90 or more is a PASS, 70 to 89 is a WARN, and less than 70
+        is a FAIL.
+
+    -repair
+        Usage: bmpinspect -repair <bmp-file.bmp> <output-file.bmp>
+        After inspecting the file, write a copy of it with some problems
+        fixed: the bfSize field is set to the actual file size, reserved
+        fields are set to 0, SizeImage is set to the calculated size for
+        uncompressed images, and the unused byte of each color table entry is
+        set to 0. The changes made are listed at the end of the output.
+
+    -dump-palette=FILE
+        After inspecting the file, write its palette to FILE as text, one
+        entry per line, in the form "0x00: #RRGGBB (R=RR, G=GG, B=BB)". If
+        the image has no palette, the file contains "(no palette)".
+
+    -dump-palette-css=FILE
+        Like -dump-palette, but write the palette as CSS custom properties
+        ("--color-0: #RRGGBB;"), in a ":root" block.
+
+    -create-bmp=WxHxBPP
+        Instead of inspecting <bmp-file.bmp>, create it. The new file is an
+        uncompressed image of the given width, height, and bit depth, with
+        all pixels set to 0. Images with a palette get a grayscale palette.
+
+    -batch-jsonl
+        Usage: bmpinspect -batch-jsonl [-output=<file>] <bmp-file.bmp>...
+        Instead of the usual output, inspect each file, and print one line of
+        JSON for it, containing its name, whether it could be read ("valid"),
+        the error if not, its version ID, dimensions, bit count, compression
+        type, and warnings. An error in one file does not stop the others
+        from being inspected.
+
+    -generate-c-struct=NAME
+        Instead of the usual output, print the whole file as C source code,
+        suitable for including in a header file: a "const uint8_t NAME[]"
+        array with 16 bytes per line, preceded by NAME_WIDTH and NAME_HEIGHT
+        macros giving the image's dimensions, and, if the image is
+        RLE-compressed, a NAME_COMPRESSED macro. The file must be readable
+        as a BMP.
+
+    -output=FILE
+        Write the -batch-jsonl or -generate-c-struct output to FILE instead
+        of stdout.
+
+    -compare-headers
+        Instead of inspecting a file, compare the header fields of two files
+        (given as <baseline.bmp> <modified.bmp>), and print each field that
+        changed, as "name: baseline-value → modified-value". If the files
+        use different header versions, only the fields they have in common
+        are compared. The pixels are not compared (see the "compare"
+        subcommand).
+
+    -hex-diff
+        Instead of inspecting a file, print the bytes of two files (given as
+        <bmp-file-1.bmp> <bmp-file-2.bmp>) side by side, 8 bytes per line.
+        Bytes that differ are highlighted in red, or, if ANSI colors are not
+        used, marked with "^^" on the next line. Runs of matching lines are
+        summarized. The output is limited to 4096 lines.
+
+    -no-limit
+        Don't limit the length of the -hex-diff output.
+
+    -stop-on-error
+        With more than one file, stop at the first file that can't be
+        inspected.
 
 Notes:
 
@@ -94,7 +486,8 @@ there's no need to separate them with spaces.
 
 === Known limitations ===
 
-* Does not inspect the contents of color profiles.
+* Of an embedded color profile, only the ICC header and the tag table are
+displayed. The tags' contents are not inspected.
 
 * Does not inspect the contents of embedded JPEG or PNG images. Such
 images are primarily for use with printers, and one would not expect to find
@@ -102,6 +495,8 @@ an actual BMP file with an embedded JPEG or PNG image.
 
 * Limited support for OS/2 2.0 BMPs.
 
-* Does not support Windows Mobile-style compression setting "BI_SRCPREROTATE".
+* Limited support for the Windows Mobile-style compression flag
+"BI_SRCPREROTATE". The flag is reported, and otherwise ignored. Pixels are
+displayed as stored, without undoing any rotation.
 */
 package documentation
@@ -0,0 +1,17 @@
+// ◄◄◄ bmpinspect/main.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package main
+
+import "fmt"
+import "os"
+import "github.com/jsummers/bmpinspect/pkg/bmpparse"
+
+func main() {
+	err := bmpparse.Main(os.Args[1:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err.Error())
+		os.Exit(1)
+	}
+}
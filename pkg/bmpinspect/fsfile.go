@@ -0,0 +1,28 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/fsfile.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpinspect
+
+import "bytes"
+import "io/fs"
+
+// InspectFSFile is like InspectBytes, but reads the BMP file from f, which
+// may come from any fs.FS (for example, an embed.FS). The whole file is read
+// into memory.
+func InspectFSFile(f fs.File, opts Options) (*Report, error) {
+	var buf bytes.Buffer
+
+	// Use the size reported by Stat, if any, to avoid reallocating the
+	// buffer. It's only a hint; the file is read to the end regardless.
+	fi, err := f.Stat()
+	if err == nil && fi.Size() > 0 && fi.Size() < 1<<30 {
+		buf.Grow(int(fi.Size()) + bytes.MinRead)
+	}
+
+	_, err = buf.ReadFrom(f)
+	if err != nil {
+		return nil, err
+	}
+	return InspectBytes(buf.Bytes(), opts)
+}
@@ -0,0 +1,165 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/hooks.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpinspect
+
+import "fmt"
+
+// Field is one field of the fileheader or infoheader, as listed in
+// Report.Fields.
+type Field struct {
+	Offset int64  // The file offset of the field
+	Name   string // The name of the field in FileHeader or the InfoHeader struct
+	Raw    []byte // The bytes of the field, as stored in the file
+	Value  string // The value, formatted for display
+}
+
+// Gap describes unused bytes between the color table and the bitmap bits.
+type Gap struct {
+	Offset int64
+	Size   int64
+	// Set if a GapHook recognized the contents of the gap.
+	Recognized bool
+}
+
+// InspectContext is passed to hooks. Hooks can use it to look at the whole
+// file, and to report problems.
+type InspectContext struct {
+	Data   []byte  // The whole file
+	Report *Report // The report so far
+}
+
+// Warn adds a warning to the report.
+func (ctx *InspectContext) Warn(offset int64, format string, a ...interface{}) {
+	ctx.Report.warn(offset, format, a...)
+}
+
+// FieldHook is called for each field, before it is added to Report.Fields.
+// If shouldPrint is false, the field is left out. If overrideValue is not "",
+// it replaces the formatted value.
+//
+// Hooks apply only to the Report made by InspectBytes (and by Parse and
+// InspectFSFile, which use it). They don't change the output of the
+// bmpinspect command, or of package bmpparse.
+type FieldHook func(ctx *InspectContext, offset int64, fieldName string,
+	rawBytes []byte) (shouldPrint bool, overrideValue string)
+
+// GapHook is called with the unused bytes before the bitmap bits, if there
+// are any. It returns true if it recognized them. Like FieldHook, it only
+// applies to the Report made by InspectBytes.
+type GapHook func(ctx *InspectContext, offset int64, data []byte) bool
+
+type fieldFormat int
+
+const (
+	fieldUnsigned fieldFormat = iota
+	fieldSigned
+	fieldHex
+	fieldString
+	fieldBytes
+)
+
+type fieldLayout struct {
+	offset int
+	size   int
+	name   string
+	format fieldFormat
+}
+
+var fileHeaderLayout = []fieldLayout{
+	{0, 2, "Type", fieldString},
+	{2, 4, "Size", fieldUnsigned},
+	{6, 2, "Reserved1", fieldUnsigned},
+	{8, 2, "Reserved2", fieldUnsigned},
+	{10, 4, "OffBits", fieldUnsigned},
+}
+
+var coreHeaderLayout = []fieldLayout{
+	{0, 4, "Size", fieldUnsigned},
+	{4, 2, "Width", fieldUnsigned},
+	{6, 2, "Height", fieldUnsigned},
+	{8, 2, "Planes", fieldUnsigned},
+	{10, 2, "BitCount", fieldUnsigned},
+}
+
+// The fields of BITMAPINFOHEADER and its successors. Only the fields that fit
+// in the header are used.
+var infoHeaderLayout = []fieldLayout{
+	{0, 4, "Size", fieldUnsigned},
+	{4, 4, "Width", fieldSigned},
+	{8, 4, "Height", fieldSigned},
+	{12, 2, "Planes", fieldUnsigned},
+	{14, 2, "BitCount", fieldUnsigned},
+	{16, 4, "Compression", fieldUnsigned},
+	{20, 4, "SizeImage", fieldUnsigned},
+	{24, 4, "XPelsPerMeter", fieldSigned},
+	{28, 4, "YPelsPerMeter", fieldSigned},
+	{32, 4, "ClrUsed", fieldUnsigned},
+	{36, 4, "ClrImportant", fieldUnsigned},
+	{40, 4, "RedMask", fieldHex},
+	{44, 4, "GreenMask", fieldHex},
+	{48, 4, "BlueMask", fieldHex},
+	{52, 4, "AlphaMask", fieldHex},
+	{56, 4, "CSType", fieldHex},
+	{60, 36, "Endpoints", fieldBytes},
+	{96, 4, "GammaRed", fieldUnsigned},
+	{100, 4, "GammaGreen", fieldUnsigned},
+	{104, 4, "GammaBlue", fieldUnsigned},
+	{108, 4, "Intent", fieldUnsigned},
+	{112, 4, "ProfileData", fieldUnsigned},
+	{116, 4, "ProfileSize", fieldUnsigned},
+	{120, 4, "Reserved", fieldUnsigned},
+}
+
+func formatField(raw []byte, format fieldFormat) string {
+	switch format {
+	case fieldString:
+		return fmt.Sprintf("%+q", string(raw))
+	case fieldBytes:
+		return fmt.Sprintf("% x", raw)
+	}
+
+	var v uint32
+	if len(raw) == 2 {
+		v = uint32(getWORD(raw))
+	} else {
+		v = getDWORD(raw)
+	}
+	switch format {
+	case fieldSigned:
+		return fmt.Sprint(int32(v))
+	case fieldHex:
+		return fmt.Sprintf("0x%08x", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// Add the fields of a header that starts at pos to Report.Fields, calling
+// the FieldHooks for each one.
+func addFields(ctx *InspectContext, opts Options, pos int64, header []byte,
+	layout []fieldLayout) {
+	for _, f := range layout {
+		if f.offset+f.size > len(header) {
+			break
+		}
+		offset := pos + int64(f.offset)
+		raw := header[f.offset : f.offset+f.size]
+		field := Field{Offset: offset, Name: f.name, Raw: raw,
+			Value: formatField(raw, f.format)}
+
+		keep := true
+		for _, hook := range opts.FieldHooks {
+			shouldPrint, overrideValue := hook(ctx, offset, f.name, raw)
+			if !shouldPrint {
+				keep = false
+			}
+			if overrideValue != "" {
+				field.Value = overrideValue
+			}
+		}
+		if keep {
+			ctx.Report.Fields = append(ctx.Report.Fields, field)
+		}
+	}
+}
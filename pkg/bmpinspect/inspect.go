@@ -0,0 +1,386 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/inspect.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpinspect
+
+import "errors"
+import "fmt"
+import "image/color"
+import "encoding/binary"
+
+// Options controls what InspectBytes puts in the Report. It only has the
+// settings that change what the Report contains. The bmpinspect command's
+// other options control how its text output is formatted, and have no
+// equivalent here; package bmpparse provides that output.
+type Options struct {
+	// If set, Report.Rows contains the bytes of each row of pixels. Only
+	// uncompressed images are supported.
+	ShowPixels bool
+
+	// The range of rows to include in Report.Rows, by row number (0 is the
+	// top row). RowEnd is not included. If RowEnd is 0, all rows from
+	// RowStart to the bottom are included. It is an error for RowStart to
+	// be negative or past the last row, or for RowEnd to be less than
+	// RowStart.
+	RowStart, RowEnd int
+
+	// Called for each header field, before it is added to Report.Fields.
+	// See FieldHook.
+	FieldHooks []FieldHook
+
+	// If not nil, called with the unused bytes before the bitmap bits.
+	GapHook GapHook
+}
+
+// FileHeader is the BITMAPFILEHEADER structure.
+type FileHeader struct {
+	Type      string // Usually "BM"
+	Size      uint32
+	Reserved1 uint16
+	Reserved2 uint16
+	OffBits   uint32
+}
+
+// InfoHeader is implemented by *BitmapCoreHeader, *BitmapInfoHeader,
+// *BitmapV4Header, and *BitmapV5Header.
+type InfoHeader interface {
+	HeaderSize() uint32
+}
+
+// BitmapCoreHeader is the 12-byte BITMAPCOREHEADER structure, used by
+// OS/2 v1 and Windows v2 BMPs.
+type BitmapCoreHeader struct {
+	Size     uint32
+	Width    uint16
+	Height   uint16
+	Planes   uint16
+	BitCount uint16
+}
+
+// BitmapInfoHeader is the 40-byte BITMAPINFOHEADER structure. It is also
+// used for the first 40 bytes of other headers that are not otherwise
+// supported, such as OS/2 v2 headers. Fields that are not present in the
+// header are 0.
+type BitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32 // Negative for top-down images
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// BitmapV4Header is the 108-byte BITMAPV4HEADER structure.
+type BitmapV4Header struct {
+	BitmapInfoHeader
+	RedMask    uint32
+	GreenMask  uint32
+	BlueMask   uint32
+	AlphaMask  uint32
+	CSType     uint32
+	Endpoints  [9]int32 // CIEXYZTRIPLE, in 2.30 fixed point format
+	GammaRed   uint32   // 16.16 fixed point format
+	GammaGreen uint32
+	GammaBlue  uint32
+}
+
+// BitmapV5Header is the 124-byte BITMAPV5HEADER structure.
+type BitmapV5Header struct {
+	BitmapV4Header
+	Intent      uint32
+	ProfileData uint32
+	ProfileSize uint32
+	Reserved    uint32
+}
+
+// HeaderSize returns the size of the header, in bytes.
+func (h *BitmapCoreHeader) HeaderSize() uint32 { return h.Size }
+
+// HeaderSize returns the size of the header, in bytes.
+func (h *BitmapInfoHeader) HeaderSize() uint32 { return h.Size }
+
+// Warning is a problem that does not prevent the file from being read.
+type Warning struct {
+	Offset  int64 // The file offset the problem relates to
+	Message string
+}
+
+// Row is one row of pixels, as stored in the file (including padding).
+type Row struct {
+	Number int   // The row number; 0 is the top row
+	Offset int64 // The file offset of the row
+	Data   []byte
+}
+
+// Report is the result of InspectBytes.
+type Report struct {
+	FileHeader FileHeader
+	InfoHeader InfoHeader
+
+	// The color masks (red, green, blue, and alpha, if present), from the
+	// infoheader or the BITFIELDS segment. nil if not present.
+	Masks []uint32
+
+	// The color table; nil if there is none. See ExtractPalette.
+	Palette []color.RGBA
+
+	// The fields of the fileheader and infoheader, in file order, except
+	// those left out by a FieldHook.
+	Fields []Field
+
+	// The unused bytes before the bitmap bits; nil if there are none.
+	Gap *Gap
+
+	// The location of the bitmap bits; nil if bfOffBits is bad.
+	Bits *BitmapBits
+
+	// Only set if Options.ShowPixels is set.
+	Rows []Row
+
+	Warnings []Warning
+}
+
+func (rpt *Report) warn(offset int64, format string, a ...interface{}) {
+	rpt.Warnings = append(rpt.Warnings, Warning{Offset: offset,
+		Message: fmt.Sprintf(format, a...)})
+}
+
+func getDWORD(d []byte) uint32 {
+	return binary.LittleEndian.Uint32(d[0:4])
+}
+
+func getWORD(d []byte) uint16 {
+	return binary.LittleEndian.Uint16(d[0:2])
+}
+
+func getLONG(d []byte) int32 {
+	return int32(getDWORD(d))
+}
+
+// DecodeInfoHeader decodes the infoheader ih, whose length is the size of
+// the header. A 12-byte header is a BITMAPCOREHEADER. The others are
+// decoded as BITMAPINFOHEADER, BITMAPV4HEADER, or BITMAPV5HEADER, depending
+// on their size; missing fields are 0, and extra fields are ignored.
+func DecodeInfoHeader(ih []byte) InfoHeader {
+	if len(ih) == 12 {
+		return &BitmapCoreHeader{
+			Size:     getDWORD(ih[0:4]),
+			Width:    getWORD(ih[4:6]),
+			Height:   getWORD(ih[6:8]),
+			Planes:   getWORD(ih[8:10]),
+			BitCount: getWORD(ih[10:12]),
+		}
+	}
+
+	// Shorter headers (OS/2 v2) are allowed to omit fields, so pad with
+	// zeroes.
+	var d [124]byte
+	copy(d[:], ih)
+
+	h3 := BitmapInfoHeader{
+		Size:          getDWORD(d[0:4]),
+		Width:         getLONG(d[4:8]),
+		Height:        getLONG(d[8:12]),
+		Planes:        getWORD(d[12:14]),
+		BitCount:      getWORD(d[14:16]),
+		Compression:   getDWORD(d[16:20]),
+		SizeImage:     getDWORD(d[20:24]),
+		XPelsPerMeter: getLONG(d[24:28]),
+		YPelsPerMeter: getLONG(d[28:32]),
+		ClrUsed:       getDWORD(d[32:36]),
+		ClrImportant:  getDWORD(d[36:40]),
+	}
+	if len(ih) != 108 && len(ih) != 124 {
+		return &h3
+	}
+
+	h4 := BitmapV4Header{
+		BitmapInfoHeader: h3,
+		RedMask:          getDWORD(d[40:44]),
+		GreenMask:        getDWORD(d[44:48]),
+		BlueMask:         getDWORD(d[48:52]),
+		AlphaMask:        getDWORD(d[52:56]),
+		CSType:           getDWORD(d[56:60]),
+		GammaRed:         getDWORD(d[96:100]),
+		GammaGreen:       getDWORD(d[100:104]),
+		GammaBlue:        getDWORD(d[104:108]),
+	}
+	for i := range h4.Endpoints {
+		h4.Endpoints[i] = getLONG(d[60+4*i : 64+4*i])
+	}
+	if len(ih) == 108 {
+		return &h4
+	}
+
+	return &BitmapV5Header{
+		BitmapV4Header: h4,
+		Intent:         getDWORD(d[108:112]),
+		ProfileData:    getDWORD(d[112:116]),
+		ProfileSize:    getDWORD(d[116:120]),
+		Reserved:       getDWORD(d[120:124]),
+	}
+}
+
+// Return the width, height, bit count, and compression of the image.
+func getImageInfo(h InfoHeader) (int, int, int, uint32) {
+	switch h := h.(type) {
+	case *BitmapCoreHeader:
+		return int(h.Width), int(h.Height), int(h.BitCount), 0
+	case *BitmapInfoHeader:
+		return int(h.Width), int(h.Height), int(h.BitCount), h.Compression
+	case *BitmapV4Header:
+		return int(h.Width), int(h.Height), int(h.BitCount), h.Compression
+	case *BitmapV5Header:
+		return int(h.Width), int(h.Height), int(h.BitCount), h.Compression
+	}
+	return 0, 0, 0, 0
+}
+
+// Collect the rows of an uncompressed image, as requested by opts.
+func getRows(rpt *Report, data []byte, opts Options, width int, height int,
+	bitCount int) error {
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+
+	rowStride := ((int64(width)*int64(bitCount) + 31) / 32) * 4
+	offBits := int64(rpt.FileHeader.OffBits)
+	// Divide instead of multiplying, which could overflow.
+	if rowStride < 1 || int64(height) > (int64(len(data))-offBits)/rowStride {
+		return errors.New("Unexpected end of file")
+	}
+
+	if opts.RowStart < 0 || (opts.RowStart > 0 && opts.RowStart >= height) ||
+		(opts.RowEnd != 0 && opts.RowEnd < opts.RowStart) {
+		return fmt.Errorf("Invalid row range %v-%v (image has %v rows)",
+			opts.RowStart, opts.RowEnd, height)
+	}
+
+	rowEnd := opts.RowEnd
+	if rowEnd == 0 || rowEnd > height {
+		rowEnd = height
+	}
+	for n := opts.RowStart; n < rowEnd; n++ {
+		rowPhysical := int64(n)
+		if !topDown {
+			rowPhysical = int64(height - 1 - n)
+		}
+		offset := offBits + rowPhysical*rowStride
+		rpt.Rows = append(rpt.Rows, Row{Number: n, Offset: offset,
+			Data: data[offset : offset+rowStride]})
+	}
+	return nil
+}
+
+// InspectBytes reads a BMP file stored in data, and returns the information
+// in its headers and color table. Problems that don't prevent the file from
+// being read are reported in Report.Warnings, not as errors.
+func InspectBytes(data []byte, opts Options) (*Report, error) {
+	rpt := new(Report)
+	fileSize := int64(len(data))
+
+	if fileSize < 18 {
+		return nil, errors.New("File is too small to be a BMP")
+	}
+
+	rpt.FileHeader = FileHeader{
+		Type:      string(data[0:2]),
+		Size:      getDWORD(data[2:6]),
+		Reserved1: getWORD(data[6:8]),
+		Reserved2: getWORD(data[8:10]),
+		OffBits:   getDWORD(data[10:14]),
+	}
+	if rpt.FileHeader.Type != "BM" {
+		return nil, errors.New("Not a BMP file")
+	}
+	ctx := &InspectContext{Data: data, Report: rpt}
+	addFields(ctx, opts, 0, data[0:14], fileHeaderLayout)
+	infoHeaderSize := int64(getDWORD(data[14:18]))
+	if int64(rpt.FileHeader.Size) != fileSize && int64(rpt.FileHeader.Size) != 14+infoHeaderSize {
+		rpt.warn(2, "Reported file size (%v) does not equal actual file size (%v)",
+			rpt.FileHeader.Size, fileSize)
+	}
+
+	if infoHeaderSize < 12 || infoHeaderSize > 1024 {
+		return nil, errors.New("Unknown BMP version")
+	}
+	if fileSize < 14+infoHeaderSize {
+		return nil, errors.New("Unexpected end of file")
+	}
+	ih := data[14 : 14+infoHeaderSize]
+	rpt.InfoHeader = DecodeInfoHeader(ih)
+	pos := 14 + infoHeaderSize
+	switch {
+	case infoHeaderSize == 12:
+		addFields(ctx, opts, 14, ih, coreHeaderLayout)
+	case infoHeaderSize == 64:
+		// OS/2 v2: only the fields it shares with BITMAPINFOHEADER.
+		addFields(ctx, opts, 14, ih[:40], infoHeaderLayout)
+	default:
+		addFields(ctx, opts, 14, ih, infoHeaderLayout)
+	}
+
+	palNumEntries, palBytesPerEntry, bitfieldsSegmentSize, err := getPaletteLayout(ih)
+	if err != nil {
+		return nil, err
+	}
+
+	if bitfieldsSegmentSize > 0 {
+		if fileSize-pos < int64(bitfieldsSegmentSize) {
+			return nil, errors.New("Unexpected end of file")
+		}
+		for i := 0; i < bitfieldsSegmentSize; i += 4 {
+			rpt.Masks = append(rpt.Masks, getDWORD(data[pos+int64(i):]))
+		}
+		pos += int64(bitfieldsSegmentSize)
+	} else if infoHeaderSize >= 52 && infoHeaderSize != 64 {
+		// BITMAPV2INFOHEADER and later
+		for i := int64(40); i < 56 && i < infoHeaderSize; i += 4 {
+			rpt.Masks = append(rpt.Masks, getDWORD(ih[i:]))
+		}
+	}
+
+	if palNumEntries > 0 {
+		palSizeInBytes := int64(palNumEntries * palBytesPerEntry)
+		if fileSize-pos < palSizeInBytes {
+			return nil, errors.New("Unexpected end of file")
+		}
+		rpt.Palette = decodePalette(data[pos:pos+palSizeInBytes], palNumEntries,
+			palBytesPerEntry)
+		pos += palSizeInBytes
+	}
+
+	if int64(rpt.FileHeader.OffBits) < pos || int64(rpt.FileHeader.OffBits) > fileSize {
+		rpt.warn(10, "Bad bfOffBits value")
+		return rpt, nil
+	}
+
+	rpt.Bits = getBitmapBits(rpt, fileSize)
+
+	if int64(rpt.FileHeader.OffBits) > pos {
+		rpt.Gap = &Gap{Offset: pos, Size: int64(rpt.FileHeader.OffBits) - pos}
+		if opts.GapHook != nil {
+			rpt.Gap.Recognized = opts.GapHook(ctx, pos, data[pos:rpt.FileHeader.OffBits])
+		}
+	}
+
+	if opts.ShowPixels {
+		width, height, bitCount, compression := getImageInfo(rpt.InfoHeader)
+		if compression != 0 && compression != 3 && compression != 6 {
+			return rpt, errors.New("Only uncompressed images are supported")
+		}
+		err = getRows(rpt, data, opts, width, height, bitCount)
+		if err != nil {
+			return rpt, err
+		}
+	}
+
+	return rpt, nil
+}
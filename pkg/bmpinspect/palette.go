@@ -0,0 +1,133 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/palette.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+// Package bmpinspect reads information from Windows BMP image files, for use
+// by other Go programs. Unlike the bmpinspect command, it does not print
+// anything.
+package bmpinspect
+
+import "errors"
+import "io"
+import "io/ioutil"
+import "image/color"
+import "encoding/binary"
+
+// Read the fileheader and infoheader, and return the infoheader.
+func readHeaders(r io.Reader) ([]byte, error) {
+	var fh [18]byte
+
+	_, err := io.ReadFull(r, fh[:])
+	if err != nil {
+		return nil, errors.New("Unexpected end of file")
+	}
+	if fh[0] != 'B' || fh[1] != 'M' {
+		return nil, errors.New("Not a BMP file")
+	}
+
+	infoHeaderSize := binary.LittleEndian.Uint32(fh[14:18])
+	if infoHeaderSize < 12 || infoHeaderSize > 1024 {
+		return nil, errors.New("Unknown BMP version")
+	}
+
+	ih := make([]byte, infoHeaderSize)
+	copy(ih[0:4], fh[14:18])
+	_, err = io.ReadFull(r, ih[4:])
+	if err != nil {
+		return nil, errors.New("Unexpected end of file")
+	}
+	return ih, nil
+}
+
+// Use the infoheader to figure out the size and location of the color table.
+// Returns the number of entries, the bytes per entry, and the size of the
+// BITFIELDS segment that precedes the color table.
+func getPaletteLayout(ih []byte) (int, int, int, error) {
+	var bitCount int
+	var clrUsed uint32
+	var compression uint32
+	var palNumEntries int
+	var palBytesPerEntry int
+	var bitfieldsSegmentSize int
+
+	if len(ih) == 12 {
+		// BITMAPCOREHEADER
+		bitCount = int(binary.LittleEndian.Uint16(ih[10:12]))
+		palBytesPerEntry = 3
+	} else {
+		if len(ih) < 16 {
+			return 0, 0, 0, errors.New("Unknown BMP version")
+		}
+		bitCount = int(binary.LittleEndian.Uint16(ih[14:16]))
+		if len(ih) >= 20 {
+			compression = binary.LittleEndian.Uint32(ih[16:20])
+		}
+		if len(ih) >= 36 {
+			clrUsed = binary.LittleEndian.Uint32(ih[32:36])
+		}
+		palBytesPerEntry = 4
+
+		if len(ih) == 40 && compression == 3 {
+			bitfieldsSegmentSize = 12 // BI_BITFIELDS
+		} else if len(ih) == 40 && compression == 6 {
+			bitfieldsSegmentSize = 16 // BI_ALPHABITFIELDS
+		}
+	}
+
+	if clrUsed > 100000 {
+		return 0, 0, 0, errors.New("Unreasonable color table size")
+	}
+	if bitCount >= 1 && bitCount <= 8 && clrUsed == 0 {
+		palNumEntries = 1 << uint(bitCount)
+	} else {
+		palNumEntries = int(clrUsed)
+	}
+	return palNumEntries, palBytesPerEntry, bitfieldsSegmentSize, nil
+}
+
+// Convert the color table entries in d to RGBA. The reserved byte of 4-byte
+// entries is ignored, and every color is opaque.
+func decodePalette(d []byte, palNumEntries int, palBytesPerEntry int) []color.RGBA {
+	pal := make([]color.RGBA, palNumEntries)
+	for i := range pal {
+		e := d[i*palBytesPerEntry:]
+		pal[i] = color.RGBA{R: e[2], G: e[1], B: e[0], A: 255}
+	}
+	return pal
+}
+
+// ExtractPalette reads a BMP file from r, and returns its color table
+// (palette). The pixel data is not read. If the image has no palette, it
+// returns (nil, nil).
+//
+// A is always set to 255. The reserved byte of each entry is not an alpha
+// value, and is usually 0.
+func ExtractPalette(r io.Reader) ([]color.RGBA, error) {
+	ih, err := readHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+
+	palNumEntries, palBytesPerEntry, bitfieldsSegmentSize, err := getPaletteLayout(ih)
+	if err != nil {
+		return nil, err
+	}
+	if palNumEntries == 0 {
+		return nil, nil
+	}
+
+	if bitfieldsSegmentSize > 0 {
+		_, err = io.CopyN(ioutil.Discard, r, int64(bitfieldsSegmentSize))
+		if err != nil {
+			return nil, errors.New("Unexpected end of file")
+		}
+	}
+
+	d := make([]byte, palNumEntries*palBytesPerEntry)
+	_, err = io.ReadFull(r, d)
+	if err != nil {
+		return nil, errors.New("Unexpected end of file")
+	}
+
+	return decodePalette(d, palNumEntries, palBytesPerEntry), nil
+}
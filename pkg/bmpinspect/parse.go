@@ -0,0 +1,67 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/parse.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpinspect
+
+import "errors"
+import "io"
+import "io/ioutil"
+
+// BitmapBits gives the location of the bitmap bits (the pixel data).
+type BitmapBits struct {
+	Offset int64 // The file offset of the bits; the same as bfOffBits
+	// The number of bytes from Offset to the end of the file, or to the
+	// start of an embedded color profile that follows the bits.
+	Size int64
+}
+
+// The CSType value PROFILE_EMBEDDED ("MBED").
+const profileEmbedded = 0x4d424544
+
+// Return the location of the bits, given the file size. The bits extend to
+// the end of the file, unless a V5 header says an embedded profile follows
+// them.
+func getBitmapBits(rpt *Report, fileSize int64) *BitmapBits {
+	bits := &BitmapBits{Offset: int64(rpt.FileHeader.OffBits)}
+	bits.Size = fileSize - bits.Offset
+	if h, ok := rpt.InfoHeader.(*BitmapV5Header); ok && h.CSType == profileEmbedded {
+		// ProfileData is relative to the start of the infoheader.
+		profileOffset := 14 + int64(h.ProfileData)
+		if profileOffset > bits.Offset && profileOffset < fileSize {
+			bits.Size = profileOffset - bits.Offset
+		}
+	}
+	return bits
+}
+
+// ReadAll reads the whole file from r. The file size is found by seeking to
+// the end, so a file that shrinks while it is being read is reported as an
+// error. If r can't seek (a pipe, for example), it is read to the end
+// instead.
+func ReadAll(r io.ReadSeeker) ([]byte, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ioutil.ReadAll(r)
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if int64(int(size)) != size {
+		return nil, errors.New("File too large")
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Parse is like InspectBytes, but reads the BMP file from r, with ReadAll.
+func Parse(r io.ReadSeeker, opts Options) (*Report, error) {
+	data, err := ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return InspectBytes(data, opts)
+}
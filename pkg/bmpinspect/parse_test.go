@@ -0,0 +1,271 @@
+// ◄◄◄ bmpinspect/pkg/bmpinspect/parse_test.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpinspect
+
+import "encoding/binary"
+import "image/color"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+// Parse one of the files in testdata.
+func parseTestFile(t *testing.T, name string, opts Options) *Report {
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rpt, err := Parse(f, opts)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return rpt
+}
+
+func TestParseFileHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		want FileHeader
+	}{
+		{"pal4.bmp", FileHeader{Type: "BM", Size: 126, OffBits: 118}},
+		{"rgb24.bmp", FileHeader{Type: "BM", Size: 90, OffBits: 54}},
+		{"v5_32.bmp", FileHeader{Type: "BM", Size: 154, OffBits: 138}},
+		{"os2v1.bmp", FileHeader{Type: "BM", Size: 82, OffBits: 74}},
+	}
+	for _, tt := range tests {
+		rpt := parseTestFile(t, tt.name, Options{})
+		if rpt.FileHeader != tt.want {
+			t.Errorf("%s: FileHeader = %+v, want %+v", tt.name, rpt.FileHeader, tt.want)
+		}
+		if len(rpt.Warnings) != 0 {
+			t.Errorf("%s: unexpected warnings: %v", tt.name, rpt.Warnings)
+		}
+	}
+}
+
+func TestParseInfoHeader(t *testing.T) {
+	rpt := parseTestFile(t, "rgb24.bmp", Options{})
+	want := BitmapInfoHeader{Size: 40, Width: 4, Height: 3, Planes: 1,
+		BitCount: 24, SizeImage: 36, XPelsPerMeter: 2835, YPelsPerMeter: 2835}
+	if h, ok := rpt.InfoHeader.(*BitmapInfoHeader); !ok || *h != want {
+		t.Errorf("rgb24.bmp: InfoHeader = %+v, want %+v", rpt.InfoHeader, want)
+	}
+
+	rpt = parseTestFile(t, "os2v1.bmp", Options{})
+	wantCore := BitmapCoreHeader{Size: 12, Width: 2, Height: 2, Planes: 1, BitCount: 4}
+	if h, ok := rpt.InfoHeader.(*BitmapCoreHeader); !ok || *h != wantCore {
+		t.Errorf("os2v1.bmp: InfoHeader = %+v, want %+v", rpt.InfoHeader, wantCore)
+	}
+
+	rpt = parseTestFile(t, "v5_32.bmp", Options{})
+	h5, ok := rpt.InfoHeader.(*BitmapV5Header)
+	if !ok {
+		t.Fatalf("v5_32.bmp: InfoHeader is %T, want *BitmapV5Header", rpt.InfoHeader)
+	}
+	if h5.Width != 2 || h5.Height != -2 || h5.BitCount != 32 || h5.Compression != 3 {
+		t.Errorf("v5_32.bmp: got %vx%v %v bpp, compression %v; want 2x-2 32 bpp, compression 3",
+			h5.Width, h5.Height, h5.BitCount, h5.Compression)
+	}
+	if h5.CSType != 0x73524742 || h5.Intent != 4 {
+		t.Errorf("v5_32.bmp: CSType = 0x%08x, Intent = %v; want 0x73524742, 4",
+			h5.CSType, h5.Intent)
+	}
+	wantMasks := []uint32{0x00ff0000, 0x0000ff00, 0x000000ff, 0xff000000}
+	if len(rpt.Masks) != len(wantMasks) {
+		t.Fatalf("v5_32.bmp: Masks = %x, want %x", rpt.Masks, wantMasks)
+	}
+	for i := range wantMasks {
+		if rpt.Masks[i] != wantMasks[i] {
+			t.Errorf("v5_32.bmp: Masks = %x, want %x", rpt.Masks, wantMasks)
+			break
+		}
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		// The color of entry i is i*step (gray)
+		step uint8
+	}{
+		{"pal4.bmp", 16, 0x11},
+		{"os2v1.bmp", 16, 0x10},
+		{"rgb24.bmp", 0, 0},
+	}
+	for _, tt := range tests {
+		rpt := parseTestFile(t, tt.name, Options{})
+		if len(rpt.Palette) != tt.n {
+			t.Errorf("%s: %v palette entries, want %v", tt.name, len(rpt.Palette), tt.n)
+			continue
+		}
+		for i, c := range rpt.Palette {
+			v := uint8(i) * tt.step
+			want := color.RGBA{v, v, v, 255}
+			if c != want {
+				t.Errorf("%s: palette entry %v = %v, want %v", tt.name, i, c, want)
+			}
+		}
+	}
+}
+
+func TestParseBitmapBits(t *testing.T) {
+	tests := []struct {
+		name string
+		want BitmapBits
+	}{
+		{"pal4.bmp", BitmapBits{Offset: 118, Size: 8}},
+		{"rgb24.bmp", BitmapBits{Offset: 54, Size: 36}},
+		{"v5_32.bmp", BitmapBits{Offset: 138, Size: 16}},
+	}
+	for _, tt := range tests {
+		rpt := parseTestFile(t, tt.name, Options{})
+		if rpt.Bits == nil || *rpt.Bits != tt.want {
+			t.Errorf("%s: Bits = %+v, want %+v", tt.name, rpt.Bits, tt.want)
+		}
+	}
+}
+
+func TestParseRows(t *testing.T) {
+	rpt := parseTestFile(t, "v5_32.bmp", Options{ShowPixels: true})
+	want := []Row{
+		{Number: 0, Offset: 138, Data: []byte{0x30, 0x20, 0x10, 0xff, 0x60, 0x50, 0x40, 0x80}},
+		{Number: 1, Offset: 146, Data: []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}},
+	}
+	if len(rpt.Rows) != len(want) {
+		t.Fatalf("got %v rows, want %v", len(rpt.Rows), len(want))
+	}
+	for i, row := range rpt.Rows {
+		if row.Number != want[i].Number || row.Offset != want[i].Offset ||
+			string(row.Data) != string(want[i].Data) {
+			t.Errorf("row %v = %+v, want %+v", i, row, want[i])
+		}
+	}
+}
+
+func TestParseRowRange(t *testing.T) {
+	rpt := parseTestFile(t, "v5_32.bmp", Options{ShowPixels: true, RowStart: 1})
+	if len(rpt.Rows) != 1 || rpt.Rows[0].Number != 1 {
+		t.Errorf("RowStart 1: got rows %+v, want row 1 only", rpt.Rows)
+	}
+
+	bad := []Options{
+		{RowStart: -100},
+		{RowStart: -1},
+		{RowStart: 2},
+		{RowStart: 1, RowEnd: -1},
+	}
+	for _, opts := range bad {
+		opts.ShowPixels = true
+		f, err := os.Open(filepath.Join("testdata", "v5_32.bmp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = Parse(f, opts)
+		f.Close()
+		if err == nil {
+			t.Errorf("RowStart %v, RowEnd %v: no error", opts.RowStart, opts.RowEnd)
+		}
+	}
+}
+
+// A width and height whose product overflows an int64 must not pass the
+// check that the rows fit in the file.
+func TestParseHugeImage(t *testing.T) {
+	data := make([]byte, 70)
+	copy(data, "BM")
+	binary.LittleEndian.PutUint32(data[2:6], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[10:14], 54)
+	binary.LittleEndian.PutUint32(data[14:18], 40)
+	binary.LittleEndian.PutUint32(data[18:22], 0x7fffffff)
+	binary.LittleEndian.PutUint32(data[22:26], 0x80000000)
+	binary.LittleEndian.PutUint16(data[26:28], 1)
+	binary.LittleEndian.PutUint16(data[28:30], 32)
+	_, err := InspectBytes(data, Options{ShowPixels: true})
+	if err == nil {
+		t.Error("no error for an image larger than the file")
+	}
+}
+
+func TestFieldHooks(t *testing.T) {
+	var seen int
+	dropReserved := func(ctx *InspectContext, offset int64, fieldName string,
+		rawBytes []byte) (bool, string) {
+		seen++
+		return fieldName != "Reserved1" && fieldName != "Reserved2", ""
+	}
+	overrideWidth := func(ctx *InspectContext, offset int64, fieldName string,
+		rawBytes []byte) (bool, string) {
+		if fieldName == "Width" {
+			return true, "four"
+		}
+		return true, ""
+	}
+
+	rpt := parseTestFile(t, "rgb24.bmp",
+		Options{FieldHooks: []FieldHook{dropReserved, overrideWidth}})
+	// 5 fileheader fields, and 11 BITMAPINFOHEADER fields
+	if seen != 16 {
+		t.Errorf("hook called %v times, want 16", seen)
+	}
+	if len(rpt.Fields) != 14 {
+		t.Errorf("got %v fields, want 14", len(rpt.Fields))
+	}
+	foundWidth := false
+	for _, f := range rpt.Fields {
+		switch f.Name {
+		case "Reserved1", "Reserved2":
+			t.Errorf("field %v was not dropped", f.Name)
+		case "Width":
+			foundWidth = true
+			if f.Value != "four" || f.Offset != 18 || string(f.Raw) != "\x04\x00\x00\x00" {
+				t.Errorf("Width = %+v, want overridden value \"four\" at offset 18", f)
+			}
+		case "Height":
+			if f.Value != "3" {
+				t.Errorf("Height = %q, want \"3\"", f.Value)
+			}
+		}
+	}
+	if !foundWidth {
+		t.Error("Width field is missing")
+	}
+}
+
+func TestGapHook(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "rgb24.bmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Insert a 4-byte gap before the bits, and adjust bfSize and bfOffBits.
+	gapData := []byte("GAP!")
+	gapped := append([]byte{}, data[:54]...)
+	gapped = append(gapped, gapData...)
+	data = append(gapped, data[54:]...)
+	binary.LittleEndian.PutUint32(data[2:6], uint32(len(data)))
+	binary.LittleEndian.PutUint32(data[10:14], 58)
+
+	for _, recognize := range []bool{false, true} {
+		var gotOffset int64
+		var gotData []byte
+		hook := func(ctx *InspectContext, offset int64, d []byte) bool {
+			gotOffset, gotData = offset, d
+			return recognize
+		}
+		rpt, err := InspectBytes(data, Options{GapHook: hook})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotOffset != 54 || string(gotData) != string(gapData) {
+			t.Errorf("hook called with offset %v, data %q; want 54, %q",
+				gotOffset, gotData, gapData)
+		}
+		want := Gap{Offset: 54, Size: 4, Recognized: recognize}
+		if rpt.Gap == nil || *rpt.Gap != want {
+			t.Errorf("Gap = %+v, want %+v", rpt.Gap, want)
+		}
+	}
+}
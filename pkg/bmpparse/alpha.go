@@ -0,0 +1,87 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/alpha.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Examine the alpha channel of a 32-bit image that has an alpha mask, and
+// print a summary of it. d is the bitmap bits.
+func reportAlphaChannel(ctx *ctx_type, d []byte) {
+	masks := getEffectiveMasks(ctx)
+	alphaMask := masks[3]
+	if ctx.bitCount != 32 || alphaMask == 0 {
+		startLine(ctx, 0)
+		ctx.print("(Alpha check: image has no alpha channel)\n")
+		return
+	}
+
+	shift := uint(0)
+	for alphaMask&(1<<shift) == 0 {
+		shift++
+	}
+	maxAlpha := alphaMask >> shift
+
+	var numPixels, numOpaque, numTransparent, numOverAlpha int64
+	minAlpha := maxAlpha
+	var highestAlpha uint32
+
+	for y := 0; y < ctx.imgHeight; y++ {
+		row := d[int64(y)*ctx.rowStride : int64(y+1)*ctx.rowStride]
+		for x := 0; x < ctx.imgWidth; x++ {
+			v := getPixelValue(row, x, 32)
+			a := (v & alphaMask) >> shift
+			numPixels++
+			if a < minAlpha {
+				minAlpha = a
+			}
+			if a > highestAlpha {
+				highestAlpha = a
+			}
+			if a == 0 {
+				numTransparent++
+			} else if a == maxAlpha {
+				numOpaque++
+			}
+
+			// In premultiplied alpha, no color sample can be larger than
+			// the alpha sample.
+			a8 := getMaskedChannel(v, alphaMask)
+			r, g, b := getPixelRGB(ctx, masks, v)
+			if r > a8 || g > a8 || b > a8 {
+				numOverAlpha++
+			}
+		}
+	}
+	if numPixels < 1 {
+		return
+	}
+
+	var class string
+	switch {
+	case numOpaque == numPixels:
+		class = "fully opaque"
+	case numTransparent == numPixels:
+		class = "fully transparent"
+	case numOpaque+numTransparent == numPixels:
+		class = "binary alpha"
+	default:
+		class = "full range alpha"
+	}
+
+	startLine(ctx, 0)
+	ctx.printf("(Alpha: %s)\n", class)
+	startLine(ctx, 0)
+	ctx.printf("(Alpha range: min=%v, max=%v)\n", minAlpha, highestAlpha)
+	startLine(ctx, 0)
+	ctx.printf("(Transparent pixels: %v of %v total)\n", numTransparent, numPixels)
+	if class == "binary alpha" {
+		startLine(ctx, 0)
+		ctx.printf("(Opaque pixels: %v, Transparent pixels: %v)\n", numOpaque, numTransparent)
+	}
+	if numOverAlpha > 0 {
+		startLine(ctx, 0)
+		ctx.printf("(Pixels with a color sample greater than alpha: %v; colors are not "+
+			"premultiplied, and may display incorrectly if treated as premultiplied)\n",
+			numOverAlpha)
+	}
+}
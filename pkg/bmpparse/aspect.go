@@ -0,0 +1,71 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/aspect.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+type aspectRatio_type struct {
+	w, h int
+	name string
+}
+
+// Well-known aspect ratios, in lowest terms, with the name they're usually
+// given if that's not the same.
+var standardAspectRatios = []aspectRatio_type{
+	{1, 1, "square"},
+	{4, 3, ""},
+	{16, 9, ""},
+	{8, 5, "16:10"},
+	{3, 2, ""},
+	{5, 4, ""},
+	{7, 3, "21:9"},
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Print the image's aspect ratio, in lowest terms. offset is the position of
+// the height field.
+func printAspectRatio(ctx *ctx_type, offset int64) {
+	if ctx.imgWidth < 1 || ctx.imgHeight < 1 {
+		return
+	}
+	g := gcd(ctx.imgWidth, ctx.imgHeight)
+	w := ctx.imgWidth / g
+	h := ctx.imgHeight / g
+
+	startLine(ctx, offset)
+	ctx.printf("(Aspect ratio: %d:%d", w, h)
+	for _, ar := range standardAspectRatios {
+		if ar.w == w && ar.h == h && ar.name != "" {
+			ctx.printf(" %s", ar.name)
+		}
+	}
+	ctx.print(")\n")
+
+	ratio := float64(ctx.imgWidth) / float64(ctx.imgHeight)
+	if ratio > 100 || ratio < 0.01 {
+		startLine(ctx, offset)
+		ctx.print("(Unusual aspect ratio)\n")
+	}
+}
+
+// If the pixels are not square, print the aspect ratio of the image as it
+// would be displayed at its physical size. offset is the position of the
+// YPelsPerMeter field.
+func printPhysicalAspectRatio(ctx *ctx_type, offset int64, xPelsPerMeter, yPelsPerMeter int32) {
+	if xPelsPerMeter <= 0 || yPelsPerMeter <= 0 || xPelsPerMeter == yPelsPerMeter {
+		return
+	}
+	if ctx.imgWidth < 1 || ctx.imgHeight < 1 {
+		return
+	}
+	physWidth := float64(ctx.imgWidth) / float64(xPelsPerMeter)
+	physHeight := float64(ctx.imgHeight) / float64(yPelsPerMeter)
+	startLine(ctx, offset)
+	ctx.printf("(Non-square pixels, physical ratio: %.2f:1)\n", physWidth/physHeight)
+}
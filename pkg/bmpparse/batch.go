@@ -0,0 +1,124 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/batch.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "encoding/json"
+import "fmt"
+import "io"
+import "os"
+
+// The information about one file, written as one line of the -batch-jsonl
+// output.
+type batchRecord struct {
+	File        string   `json:"file"`
+	Valid       bool     `json:"valid"`
+	Error       string   `json:"error,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	BitCount    int      `json:"bitCount"`
+	Compression string   `json:"compression,omitempty"`
+	Warnings    []string `json:"warnings"`
+}
+
+func marshalBatchRecord(r batchRecord) []byte {
+	if r.Warnings == nil {
+		// Write an empty list, not null.
+		r.Warnings = []string{}
+	}
+	d, err := json.Marshal(r)
+	if err != nil {
+		// Can't happen; all the fields are strings, numbers, and bools.
+		panic(err)
+	}
+	return d
+}
+
+// Call f, and if it panics, return the panic as an error. When inspecting a
+// batch of files, a bug triggered by one file shouldn't stop the others
+// from being inspected.
+func catchPanic(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Internal error: %v", r)
+		}
+	}()
+	return f()
+}
+
+// Inspect fileName without printing anything, and return what was found.
+func inspectForBatch(fileName string) batchRecord {
+	r := batchRecord{File: fileName}
+
+	ctx, err := newFileCtx(fileName)
+	if err == nil {
+		defer ctx.file.close()
+		ctx.suppressOutput = true
+		err = catchPanic(func() error { return readBmp(ctx) })
+		r.Version = ctx.bmpVerID
+		r.Width = ctx.imgWidth
+		r.Height = ctx.imgHeight
+		r.BitCount = ctx.bitCount
+		r.Compression = ctx.compressionType
+		r.Warnings = ctx.warnings
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Valid = err == nil
+	return r
+}
+
+// Inspect each file, and write one JSON object per line to outputPath, or
+// to stdout if outputPath is "". A file that can't be read doesn't stop the
+// others from being inspected.
+func writeBatchJSONL(fileNames []string, outputPath string) error {
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		f, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	for _, fileName := range fileNames {
+		d := marshalBatchRecord(inspectForBatch(fileName))
+		_, err := out.Write(append(d, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Inspect each of the named files in turn, with the options set in template.
+// Unless stopOnError is set, an error in one file (or a panic) is printed,
+// and the rest of the files are still inspected.
+func inspectFiles(template *ctx_type, fileNames []string, stopOnError bool,
+	inspect func(ctx *ctx_type) error) error {
+	numErrors := 0
+	for _, fileName := range fileNames {
+		ctx := new(ctx_type)
+		*ctx = *template
+		ctx.fileName = fileName
+		ctx.printf("=== %s ===\n", fileName)
+		err := catchPanic(func() error { return inspect(ctx) })
+		if err != nil {
+			if stopOnError {
+				return err
+			}
+			// After a panic, ctx may still be suppressing its output.
+			template.printf("Error: %v\n", err.Error())
+			numErrors++
+		}
+		template.print("\n")
+	}
+	if numErrors > 0 {
+		return fmt.Errorf("%d of %d files could not be inspected", numErrors, len(fileNames))
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/bitmaparray.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+
+// The maximum number of Bitmap Array headers we'll follow, to guard against
+// cycles.
+const maxBitmapArrayEntries = 64
+
+type bitmapArrayEntry_type struct {
+	pos         int64 // File position of the BITMAPARRAYFILEHEADER
+	imgWidth    int
+	imgHeight   int
+	hasImgSizes bool
+}
+
+// Read the dimensions of the image that follows a BITMAPARRAYFILEHEADER.
+// The image's own FILEHEADER is at pos+14, and its INFOHEADER at pos+28.
+func readBitmapArrayImageSize(ctx *ctx_type, e *bitmapArrayEntry_type) {
+	ihPos := e.pos + 28
+	if ctx.fileSize-ihPos < 4 {
+		return
+	}
+	ihSize := getDWORD(ctx.file.bytes(ihPos, ihPos+4))
+
+	if ihSize == 12 {
+		if ctx.fileSize-ihPos < 8 {
+			return
+		}
+		e.imgWidth = int(getWORD(ctx.file.bytes(ihPos+4, ihPos+6)))
+		e.imgHeight = int(getWORD(ctx.file.bytes(ihPos+6, ihPos+8)))
+	} else {
+		if ctx.fileSize-ihPos < 12 {
+			return
+		}
+		e.imgWidth = int(getLONG(ctx.file.bytes(ihPos+4, ihPos+8)))
+		e.imgHeight = int(getLONG(ctx.file.bytes(ihPos+8, ihPos+12)))
+		if e.imgHeight < 0 {
+			e.imgHeight = -e.imgHeight
+		}
+	}
+	e.hasImgSizes = true
+}
+
+func printDisplaySize(ctx *ctx_type, n uint16, descr string) {
+	ctx.printf("%v", n)
+	if n == 0 {
+		ctx.print(" (0 = use screen resolution)")
+	} else {
+		ctx.printf(" (%s)", descr)
+	}
+	ctx.print("\n")
+}
+
+// Inspect one BITMAPARRAYFILEHEADER. Returns the offNext, cxDisplay, and
+// cyDisplay fields.
+func inspectBitmapArrayHeader(ctx *ctx_type, d []byte, n int,
+	e *bitmapArrayEntry_type) (uint32, uint16, uint16) {
+
+	startLine(ctx, 0)
+	ctx.printf("----- Bitmap Array header %d -----\n", n)
+
+	ctx.setJSONValue(string(d[0:2]))
+	ctx.pfxPrintf(0, "usType", "0x%02x 0x%02x (%+q)\n", d[0], d[1], string(d[0:2]))
+
+	cbSize := getDWORD(d[2:6])
+	ctx.pfxPrintf(2, "cbSize", "%v\n", cbSize)
+
+	offNext := getDWORD(d[6:10])
+	ctx.pfxPrintf(6, "offNext", "%v\n", offNext)
+
+	cxDisplay := getWORD(d[10:12])
+	ctx.setJSONValue(cxDisplay)
+	ctx.pfxPrintf(10, "cxDisplay", "")
+	printDisplaySize(ctx, cxDisplay, "display surface width")
+
+	cyDisplay := getWORD(d[12:14])
+	ctx.setJSONValue(cyDisplay)
+	ctx.pfxPrintf(12, "cyDisplay", "")
+	printDisplaySize(ctx, cyDisplay, "display surface height")
+
+	readBitmapArrayImageSize(ctx, e)
+	if e.hasImgSizes {
+		startLine(ctx, 14)
+		ctx.printf("(Image size: %vx%v)\n", e.imgWidth, e.imgHeight)
+	}
+
+	return offNext, cxDisplay, cyDisplay
+}
+
+// Read a Bitmap Array file: a linked list of BITMAPARRAYFILEHEADER
+// structures, each followed by an embedded image.
+func readBitmapArray(ctx *ctx_type) error {
+	var entries []bitmapArrayEntry_type
+	var cxDisplay, cyDisplay uint16
+
+	for n := 0; ; n++ {
+		if n >= maxBitmapArrayEntries {
+			return errors.New("Too many Bitmap Array entries")
+		}
+		if ctx.fileSize-ctx.pos < 14 {
+			return errors.New("Unexpected end of file")
+		}
+		if string(ctx.file.bytes(ctx.pos, ctx.pos+2)) != "BA" {
+			return errors.New("Bad Bitmap Array header")
+		}
+
+		var e bitmapArrayEntry_type
+		var offNext uint32
+		e.pos = ctx.pos
+		offNext, cxDisplay, cyDisplay = inspectBitmapArrayHeader(ctx,
+			ctx.file.bytes(ctx.pos, ctx.pos+14), n, &e)
+		entries = append(entries, e)
+
+		if offNext == 0 {
+			break
+		}
+		if int64(offNext) <= ctx.pos || int64(offNext) >= ctx.fileSize {
+			return errors.New("Bad offNext value")
+		}
+		ctx.pos = int64(offNext)
+	}
+
+	// Now that we know all the image sizes, make sure they fit on the
+	// display surface. The display size is supposed to be the same in every
+	// header, so we only use the last one.
+	for n, e := range entries {
+		if !e.hasImgSizes {
+			continue
+		}
+		if (cxDisplay != 0 && e.imgWidth > int(cxDisplay)) ||
+			(cyDisplay != 0 && e.imgHeight > int(cyDisplay)) {
+			ctx.warn("header", "Sub-image %d (%vx%v) exceeds display surface (%vx%v)",
+				n, e.imgWidth, e.imgHeight, cxDisplay, cyDisplay)
+		}
+	}
+
+	starts := bitmapArrayStarts(ctx, entries)
+	for n, e := range entries {
+		err := readBitmapArrayImage(ctx, n, e, starts)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The images' bits usually follow all the headers.
+	ctx.pos = ctx.fileSize
+	return nil
+}
+
+// Return the file positions where the structures in a Bitmap Array start:
+// the headers, and each image's bits. Used to guess where each image's bits
+// end.
+func bitmapArrayStarts(ctx *ctx_type, entries []bitmapArrayEntry_type) []int64 {
+	var starts []int64
+	addImage := func(fhPos int64) {
+		starts = append(starts, fhPos)
+		if ctx.fileSize-fhPos >= 14 {
+			starts = append(starts, int64(getDWORD(ctx.file.bytes(fhPos+10, fhPos+14))))
+		}
+	}
+
+	for _, e := range entries {
+		starts = append(starts, e.pos)
+		addImage(e.pos + 14)
+
+		// A color icon or pointer has a second fileheader, after the
+		// mask's infoheader and its 2-entry color table.
+		fhPos := e.pos + 14
+		if ctx.fileSize-fhPos < 18 {
+			continue
+		}
+		fileType := string(ctx.file.bytes(fhPos, fhPos+2))
+		if fileType != "CI" && fileType != "CP" {
+			continue
+		}
+		ihSize := int64(getDWORD(ctx.file.bytes(fhPos+14, fhPos+18)))
+		palBytesPerEntry := int64(4)
+		if ihSize == 12 {
+			palBytesPerEntry = 3
+		}
+		addImage(fhPos + 14 + ihSize + 2*palBytesPerEntry)
+	}
+	return starts
+}
+
+// Return the position of the first of starts after pos, or the end of the
+// file.
+func nextBitmapArrayStart(ctx *ctx_type, starts []int64, pos int64) int64 {
+	end := ctx.fileSize
+	for _, s := range starts {
+		if s > pos && s < end {
+			end = s
+		}
+	}
+	return end
+}
+
+// Inspect the image that follows a BITMAPARRAYFILEHEADER. For a color icon
+// or pointer, the color image that follows the mask is also inspected.
+func readBitmapArrayImage(parent *ctx_type, n int, e bitmapArrayEntry_type,
+	starts []int64) error {
+	pos := e.pos + 14
+	startLineAbsolute(parent, pos)
+	parent.printf("----- Bitmap Array entry %d -----\n", n)
+
+	iconColorImage := false
+	for {
+		if parent.fileSize-pos < 14 {
+			return errors.New("Unexpected end of file")
+		}
+		ctx := newSubImageCtx(parent)
+		ctx.pos = pos
+		ctx.inBitmapArray = true
+		ctx.iconColorImage = iconColorImage
+		// Each image is inspected as if the file ended where its bits
+		// seem to end.
+		offBits := int64(getDWORD(parent.file.bytes(pos+10, pos+14)))
+		ctx.fileSize = nextBitmapArrayStart(parent, starts, offBits)
+		if ctx.fileSize < pos+18 {
+			ctx.fileSize = parent.fileSize
+		}
+
+		err := readBmp(ctx)
+		startLineAbsolute(ctx, ctx.fileSize)
+		ctx.printf("----- End of Bitmap Array entry %d -----\n", n)
+		parent.score = ctx.score
+		if err != nil {
+			return err
+		}
+
+		if iconColorImage || (ctx.fileType != "CI" && ctx.fileType != "CP") || ctx.palPos == 0 {
+			return nil
+		}
+		// The color image's fileheader follows the mask's color table.
+		pos = ctx.palPos + int64(ctx.palSizeInBytes)
+		if parent.fileSize-pos < 18 || string(parent.file.bytes(pos, pos+2)) != ctx.fileType {
+			return nil
+		}
+		iconColorImage = true
+	}
+}
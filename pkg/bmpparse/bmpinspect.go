@@ -0,0 +1,4108 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/bmpinspect.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+// Package bmpparse implements the bmpinspect command: it parses a Windows
+// BMP file, and describes its contents in detail. The bmpinspect program is
+// a thin wrapper around Main.
+package bmpparse
+
+import "bufio"
+import "bytes"
+import "errors"
+import "flag"
+import "fmt"
+import "io"
+import "hash/crc32"
+import "math"
+import "math/bits"
+import "os"
+import "strings"
+import "unicode/utf8"
+import "io/ioutil"
+import "encoding/binary"
+import "github.com/jsummers/bmpinspect/pkg/bmpinspect"
+
+var fileTypeNames = map[string]string{
+	"BA": "Bitmap Array",
+	"BM": "Bitmap",
+	"CI": "Color Icon",
+	"CP": "Color Pointer",
+	"IC": "Icon",
+	"PT": "Pointer",
+}
+
+const (
+	bI_RGB       = 0
+	bI_RLE8      = 1
+	bI_RLE4      = 2
+	bI_BITFIELDS = 3
+	bI_HUFFMAN1D = 3
+	bI_JPEG      = 4
+	bI_RLE24     = 4
+	bI_PNG       = 5
+	// Some sources say 4, but 6 is confirmed by the Windows CE SDK.
+	bI_ALPHABITFIELDS = 6
+	bI_SRCPREROTATE   = 0x8000 // Unconfirmed.
+)
+
+const (
+	lCS_CALIBRATED_RGB      = 0
+	lCS_sRGB                = 0x73524742
+	lCS_WINDOWS_COLOR_SPACE = 0x57696e20
+	pROFILE_LINKED          = 0x4c494e4b
+	pROFILE_EMBEDDED        = 0x4d424544
+)
+
+var csTypeNames = map[uint32]string{
+	lCS_CALIBRATED_RGB:      "LCS_CALIBRATED_RGB",
+	1:                       "LCS_DEVICE_RGB (?)",
+	2:                       "LCS_DEVICE_CMYK (?)",
+	lCS_sRGB:                "LCS_sRGB",
+	lCS_WINDOWS_COLOR_SPACE: "LCS_WINDOWS_COLOR_SPACE",
+	pROFILE_LINKED:          "PROFILE_LINKED",
+	pROFILE_EMBEDDED:        "PROFILE_EMBEDDED",
+}
+
+var intentNames = map[uint32]string{
+	1: "LCS_GM_BUSINESS (Saturation)",
+	2: "LCS_GM_GRAPHICS (Relative)",
+	4: "LCS_GM_IMAGES (Perceptual)",
+	8: "LCS_GM_ABS_COLORIMETRIC",
+}
+
+// Documentation references for each field, displayed by the -spec-refs
+// option. The keys are field names as displayed (see translateFieldName).
+// OS/2 field names have no prefix, so they are qualified by the version ID.
+var fieldSpecRefs = map[string]string{
+	"bfType":              "MSDN: BITMAPFILEHEADER.bfType",
+	"bfSize":              "MSDN: BITMAPFILEHEADER.bfSize",
+	"bfReserved1":         "MSDN: BITMAPFILEHEADER.bfReserved1",
+	"bfReserved2":         "MSDN: BITMAPFILEHEADER.bfReserved2",
+	"bfOffBits":           "MSDN: BITMAPFILEHEADER.bfOffBits",
+	"bcWidth":             "MSDN: BITMAPCOREHEADER.bcWidth",
+	"bcHeight":            "MSDN: BITMAPCOREHEADER.bcHeight",
+	"bcPlanes":            "MSDN: BITMAPCOREHEADER.bcPlanes",
+	"bcBitCount":          "MSDN: BITMAPCOREHEADER.bcBitCount",
+	"biWidth":             "MSDN: BITMAPINFOHEADER.biWidth",
+	"biHeight":            "MSDN: BITMAPINFOHEADER.biHeight",
+	"biPlanes":            "MSDN: BITMAPINFOHEADER.biPlanes",
+	"biBitCount":          "MSDN: BITMAPINFOHEADER.biBitCount",
+	"biCompression":       "MSDN: BITMAPINFOHEADER.biCompression",
+	"biSizeImage":         "MSDN: BITMAPINFOHEADER.biSizeImage",
+	"biXPelsPerMeter":     "MSDN: BITMAPINFOHEADER.biXPelsPerMeter",
+	"biYPelsPerMeter":     "MSDN: BITMAPINFOHEADER.biYPelsPerMeter",
+	"biClrUsed":           "MSDN: BITMAPINFOHEADER.biClrUsed",
+	"biClrImportant":      "MSDN: BITMAPINFOHEADER.biClrImportant",
+	"biRedMask":           "MSDN: BITMAPV3INFOHEADER.biRedMask",
+	"biGreenMask":         "MSDN: BITMAPV3INFOHEADER.biGreenMask",
+	"biBlueMask":          "MSDN: BITMAPV3INFOHEADER.biBlueMask",
+	"biAlphaMask":         "MSDN: BITMAPV3INFOHEADER.biAlphaMask",
+	"bV4Width":            "MSDN: BITMAPV4HEADER.bV4Width",
+	"bV4Height":           "MSDN: BITMAPV4HEADER.bV4Height",
+	"bV4Planes":           "MSDN: BITMAPV4HEADER.bV4Planes",
+	"bV4BitCount":         "MSDN: BITMAPV4HEADER.bV4BitCount",
+	"bV4Compression":      "MSDN: BITMAPV4HEADER.bV4Compression",
+	"bV4SizeImage":        "MSDN: BITMAPV4HEADER.bV4SizeImage",
+	"bV4XPelsPerMeter":    "MSDN: BITMAPV4HEADER.bV4XPelsPerMeter",
+	"bV4YPelsPerMeter":    "MSDN: BITMAPV4HEADER.bV4YPelsPerMeter",
+	"bV4ClrUsed":          "MSDN: BITMAPV4HEADER.bV4ClrUsed",
+	"bV4ClrImportant":     "MSDN: BITMAPV4HEADER.bV4ClrImportant",
+	"bV4RedMask":          "MSDN: BITMAPV4HEADER.bV4RedMask",
+	"bV4GreenMask":        "MSDN: BITMAPV4HEADER.bV4GreenMask",
+	"bV4BlueMask":         "MSDN: BITMAPV4HEADER.bV4BlueMask",
+	"bV4AlphaMask":        "MSDN: BITMAPV4HEADER.bV4AlphaMask",
+	"bV4CSType":           "MSDN: BITMAPV4HEADER.bV4CSType",
+	"bV4Endpoints":        "MSDN: BITMAPV4HEADER.bV4Endpoints",
+	"bV4GammaRed":         "MSDN: BITMAPV4HEADER.bV4GammaRed",
+	"bV4GammaGreen":       "MSDN: BITMAPV4HEADER.bV4GammaGreen",
+	"bV4GammaBlue":        "MSDN: BITMAPV4HEADER.bV4GammaBlue",
+	"bV5Width":            "MSDN: BITMAPV5HEADER.bV5Width",
+	"bV5Height":           "MSDN: BITMAPV5HEADER.bV5Height",
+	"bV5Planes":           "MSDN: BITMAPV5HEADER.bV5Planes",
+	"bV5BitCount":         "MSDN: BITMAPV5HEADER.bV5BitCount",
+	"bV5Compression":      "MSDN: BITMAPV5HEADER.bV5Compression",
+	"bV5SizeImage":        "MSDN: BITMAPV5HEADER.bV5SizeImage",
+	"bV5XPelsPerMeter":    "MSDN: BITMAPV5HEADER.bV5XPelsPerMeter",
+	"bV5YPelsPerMeter":    "MSDN: BITMAPV5HEADER.bV5YPelsPerMeter",
+	"bV5ClrUsed":          "MSDN: BITMAPV5HEADER.bV5ClrUsed",
+	"bV5ClrImportant":     "MSDN: BITMAPV5HEADER.bV5ClrImportant",
+	"bV5RedMask":          "MSDN: BITMAPV5HEADER.bV5RedMask",
+	"bV5GreenMask":        "MSDN: BITMAPV5HEADER.bV5GreenMask",
+	"bV5BlueMask":         "MSDN: BITMAPV5HEADER.bV5BlueMask",
+	"bV5AlphaMask":        "MSDN: BITMAPV5HEADER.bV5AlphaMask",
+	"bV5CSType":           "MSDN: BITMAPV5HEADER.bV5CSType",
+	"bV5Endpoints":        "MSDN: BITMAPV5HEADER.bV5Endpoints",
+	"bV5GammaRed":         "MSDN: BITMAPV5HEADER.bV5GammaRed",
+	"bV5GammaGreen":       "MSDN: BITMAPV5HEADER.bV5GammaGreen",
+	"bV5GammaBlue":        "MSDN: BITMAPV5HEADER.bV5GammaBlue",
+	"bV5Intent":           "MSDN: BITMAPV5HEADER.bV5Intent",
+	"bV5ProfileData":      "MSDN: BITMAPV5HEADER.bV5ProfileData",
+	"bV5ProfileSize":      "MSDN: BITMAPV5HEADER.bV5ProfileSize",
+	"bV5Reserved":         "MSDN: BITMAPV5HEADER.bV5Reserved",
+	"os2v1:cbSize":        "OS2: BITMAPFILEHEADER.cbSize",
+	"os2v1:xHotspot":      "OS2: BITMAPFILEHEADER.xHotspot",
+	"os2v1:yHotspot":      "OS2: BITMAPFILEHEADER.yHotspot",
+	"os2v1:offBits":       "OS2: BITMAPFILEHEADER.offBits",
+	"os2v1:Width":         "OS2: BITMAPINFOHEADER.cx",
+	"os2v1:Height":        "OS2: BITMAPINFOHEADER.cy",
+	"os2v1:Planes":        "OS2: BITMAPINFOHEADER.cPlanes",
+	"os2v1:BitCount":      "OS2: BITMAPINFOHEADER.cBitCount",
+	"os2v2:cbSize":        "OS2: BITMAPFILEHEADER2.cbSize",
+	"os2v2:xHotspot":      "OS2: BITMAPFILEHEADER2.xHotspot",
+	"os2v2:yHotspot":      "OS2: BITMAPFILEHEADER2.yHotspot",
+	"os2v2:offBits":       "OS2: BITMAPFILEHEADER2.offBits",
+	"os2v2:Width":         "OS2: BITMAPINFOHEADER2.cx",
+	"os2v2:Height":        "OS2: BITMAPINFOHEADER2.cy",
+	"os2v2:Planes":        "OS2: BITMAPINFOHEADER2.cPlanes",
+	"os2v2:BitCount":      "OS2: BITMAPINFOHEADER2.cBitCount",
+	"os2v2:Compression":   "OS2: BITMAPINFOHEADER2.ulCompression",
+	"os2v2:SizeImage":     "OS2: BITMAPINFOHEADER2.cbImage",
+	"os2v2:XResolution":   "OS2: BITMAPINFOHEADER2.cxResolution",
+	"os2v2:YResolution":   "OS2: BITMAPINFOHEADER2.cyResolution",
+	"os2v2:ClrUsed":       "OS2: BITMAPINFOHEADER2.cclrUsed",
+	"os2v2:ClrImportant":  "OS2: BITMAPINFOHEADER2.cclrImportant",
+	"os2v2:Units":         "OS2: BITMAPINFOHEADER2.usUnits",
+	"os2v2:Reserved":      "OS2: BITMAPINFOHEADER2.usReserved",
+	"os2v2:Recording":     "OS2: BITMAPINFOHEADER2.usRecording",
+	"os2v2:Rendering":     "OS2: BITMAPINFOHEADER2.usRendering",
+	"os2v2:Size1":         "OS2: BITMAPINFOHEADER2.cSize1",
+	"os2v2:Size2":         "OS2: BITMAPINFOHEADER2.cSize2",
+	"os2v2:ColorEncoding": "OS2: BITMAPINFOHEADER2.ulColorEncoding",
+	"os2v2:Identifier":    "OS2: BITMAPINFOHEADER2.ulIdentifier",
+}
+
+// The values of fields that have a default value, used by the
+// -diff-from-defaults option. The keys are BITMAPFILEHEADER and
+// BITMAPINFOHEADER field names, which also stand for the same fields in
+// other versions (see defaultValueKey). The values must have the same type
+// as the value passed to pfxPrintf.
+var fieldDefaults = map[string]interface{}{
+	"bfReserved1":    uint16(0),
+	"bfReserved2":    uint16(0),
+	"biPlanes":       uint16(1),
+	"biCompression":  uint32(bI_RGB),
+	"biClrUsed":      uint32(0),
+	"biClrImportant": uint32(0),
+	"biProfileData":  uint32(0),
+	"biProfileSize":  uint32(0),
+	"biReserved":     uint32(0),
+}
+
+// Values of the OS/2 2.x ColorEncoding field. Only BCCE_RGB is known to be
+// used in practice. BCCE_YCrCb is not confirmed.
+const (
+	bCCE_RGB     = 0
+	bCCE_PALETTE = 1
+	bCCE_YCrCb   = 5
+)
+
+var bitmapOS2V2ColorEncodingNames = map[uint32]string{
+	bCCE_RGB:     "BCCE_RGB",
+	bCCE_PALETTE: "BCCE_PALETTE",
+	bCCE_YCrCb:   "BCCE_YCrCb (?)",
+}
+
+// The Rendering field of OS/2 2.x BMPs is the halftoning algorithm, not the
+// compression algorithm (which is in the Compression field).
+var os2RenderingNames = map[uint16]string{
+	0: "BRH_NOTHALFTONED",
+	1: "BRH_ERRORDIFFUSION",
+	2: "BRH_PANDA",
+	3: "BRH_SUPERCIRCLE",
+}
+
+// The Recording field of OS/2 2.x BMPs. Only BRA_BOTTOMUP is documented.
+var os2RecordingNames = map[uint16]string{
+	0: "BRA_BOTTOMUP",
+	1: "BRA_TOPDOWN (?)",
+}
+
+// The Identifier field of OS/2 2.x BMPs is reserved for application use.
+// No registered values are documented.
+var os2IdentifierNames = map[uint32]string{
+	0: "not set",
+}
+
+type versionInfo_type struct {
+	prefix                string
+	inspectInfoheaderFunc func(ctx *ctx_type, d []byte) error
+}
+
+// Information about the different BMP versions.
+var versionInfo = map[string]versionInfo_type{
+	"os2v1":   {"", inspectInfoheaderOS2},
+	"os2v2":   {"", inspectInfoheaderOS2V2},
+	"winv2":   {"bc", inspectInfoheaderWinV2},
+	"winv3":   {"bi", inspectInfoheaderV3},
+	"wince":   {"bi", inspectInfoheaderWinCE},
+	"partial": {"bi", inspectInfoheaderPartial},
+	"52":      {"bi", inspectInfoheaderV4},
+	"56":      {"bi", inspectInfoheaderV4},
+	"winv4":   {"bV4", inspectInfoheaderV4},
+	"winv5":   {"bV5", inspectInfoheaderV5},
+}
+
+var versionIDToName = map[string]string{
+	"os2v1":   "OS/2 BMP v1",
+	"os2v2":   "OS/2 BMP v2",
+	"winv2":   "Windows BMP v2",
+	"winv3":   "Windows BMP v3",
+	"wince":   "Windows CE BMP",
+	"partial": "Partial BITMAPINFOHEADER (non-standard)",
+	"52":      "BITMAPV2INFOHEADER",
+	"56":      "BITMAPV3INFOHEADER",
+	"winv4":   "Windows BMP v4",
+	"winv5":   "Windows BMP v5",
+	"unknown": "Unknown",
+}
+
+// The name of the header structure, as used by the Microsoft documentation.
+// (The OS/2 v2 header is not in that documentation, and has various names.)
+var versionIDToStructName = map[string]string{
+	"os2v1": "BITMAPCOREHEADER",
+	"os2v2": "OS22XBITMAPHEADER",
+	"winv2": "BITMAPCOREHEADER2",
+	"winv3": "BITMAPINFOHEADER",
+	"52":    "BITMAPV2INFOHEADER",
+	"56":    "BITMAPV3INFOHEADER",
+	"winv4": "BITMAPV4HEADER",
+	"winv5": "BITMAPV5HEADER",
+}
+
+// Print the "Version detected" line.
+func printVersionDetected(ctx *ctx_type) {
+	startLine(ctx, 0)
+	ctx.printf("(Version detected: %s", ctx.bmpVerName)
+	structName := versionIDToStructName[ctx.bmpVerID]
+	if structName != "" && structName != ctx.bmpVerName {
+		ctx.printf(" / %s", structName)
+	}
+	ctx.printf(", size=%v)\n", ctx.infoHeaderSize)
+}
+
+// The options set by the user, which apply to every image in the file.
+type options_type struct {
+	specRefs bool
+	rawBytes bool // Show the bytes of each infoheader field
+
+	showRawInfoheader bool // Show the infoheader bytes before parsing it
+
+	rowChecksums     bool // Print a CRC-32 of each row's bytes
+	rowChecksumsOnly bool // Print the CRC-32s instead of the pixel values
+	rowMap           bool // Print a table of the file offset of each row
+	annotateRLE      bool // Print the number of pixels in each RLE row
+	missingEOBMPOK   bool // Don't report RLE data that has no EOBMP code
+	checkUnusedBits  bool // Check the unused bits of 16- and 32-bit pixels
+	checkConventions bool // Compare the palette to the usual Windows palette
+	palettePreview   bool // Show the palette colors as colored blocks
+	alphaCheck       bool // Examine the alpha channel of 32-bit images
+	complexity       bool // Print measures of the image's complexity
+	pixelUniqueness  bool // Print the fraction of pixels with a unique value
+	lzwEstimate      bool // Estimate the size of the pixels compressed by LZW
+	rleAnalysis      bool // Print an analysis of the RLE tokens
+	duplicateRows    bool // Report rows that are identical to the previous row
+	findPixel        bool // Find the pixels of color findPixelColor
+	findPixelColor   [3]uint8
+	checkSRGB        bool // Check the gamma and endpoints against CSType
+	useColor         bool // Use ANSI colors to highlight some things
+	diffFromDefaults bool // Hide fields that have their default value
+
+	decodeRLE decodeRLEMode_type // Print each pixel of RLE runs
+
+	noLimit bool // Don't limit the length of the -hex-diff output
+
+	pixelOffsets bool // Print the file offset of each pixel
+
+	showPaletteRGB bool // Print the color of each palette index in the pixels
+	noWidthLimit   bool // Use showPaletteRGB even for wide images
+	decodePixels   bool // Print the components of 16- and 32-bit pixels
+
+	// The pixel format to use instead of the one in the header, for
+	// displaying the pixels; "" for none. See pixelFormats.
+	pixelFormatOverride string
+
+	// The order in which to show the palette a second time (see
+	// paletteSortModes), or "index" to show it only once.
+	paletteSort string
+
+	validate bool // Print a validity score at the end
+
+	inspectThumbnail bool // Inspect a thumbnail found by detectThumbnail
+	xmp              bool // Look for XMP metadata before the bitmap bits
+
+	showGaps bool // Print details about each block of unused bytes
+
+	statistics bool // Print statistics about the image at the end
+
+	showStatus bool // Show the current section on stderr
+
+	// Sections the user asked us not to display
+	skipSections sectionList_type
+	// If not "", only this section is displayed (see sectionNames).
+	showOnly string
+
+	tableHeaders bool // Print the headers as tables
+	tableUnicode bool // Use box-drawing characters in tables
+}
+
+type ctx_type struct {
+	options_type
+
+	fileName string
+	file     *fileData_type
+	fileSize int64
+	pos      int64
+
+	printPixels bool
+
+	rowFileOffset int64 // The file offset of the row being printed
+
+	score validityScore
+
+	stats *imageStatistics
+
+	statusPending bool // A status line is being displayed
+
+	// While a header table is being collected, output goes to it.
+	table *tableFormatter
+
+	// Where the output goes. If nil, it goes to stdout.
+	out io.Writer
+	// If set, the output is recorded as Lines instead, for Parse.
+	recorder *lineRecorder
+
+	// If set, all output is discarded.
+	suppressOutput bool
+	// If set, warnings are printed even if suppressOutput is set.
+	alwaysShowWarnings bool
+
+	// With -format=json, the result being collected; otherwise nil.
+	json *inspectionResult
+	// The section being printed (see sectionNames); "" if none.
+	section string
+
+	// A documentation reference to be displayed at the end of the current
+	// line, if specRefs is set.
+	pendingSpecRef string
+	// Raw bytes to be displayed at the end of the current line, if rawBytes
+	// is set.
+	pendingRawBytes string
+	// If set, output is discarded up to the end of the current line.
+	suppressLine bool
+	// The number of characters printed on the current line.
+	column int
+
+	fileType    string // Usually "BM"
+	bmpVerID    string // Version name used by bmpinspect: ("os2v1", "winv3", etc.)
+	bmpVerName  string
+	isWindowsCE bool
+	// The image has no FILEHEADER, and ctx.pos starts at the infoheader.
+	noFileheader bool
+	// The image is in a Bitmap Array. Its bits usually follow all the
+	// headers in the file, so a gap before them is expected.
+	inBitmapArray bool
+	// The image is the color image of a CI or CP, which follows the mask.
+	iconColorImage bool
+	// The position of a possible thumbnail image, as found by
+	// detectThumbnail; 0 if none.
+	thumbnailPos int64
+
+	bitCount        int
+	imgWidth        int
+	imgHeight       int
+	bfOffBits       uint32
+	infoHeaderSize  uint32 // bcSize, biSize, etc.
+	sizeImage       uint32 // The biSizeImage field; 0 if not available
+	compressionCode uint32 // The biCompression field, without BI_SRCPREROTATE
+	hasSrcPrerotate bool   // The BI_SRCPREROTATE flag was set
+	xPelsPerMeter   int32  // The biXPelsPerMeter field; 0 if not available
+	yPelsPerMeter   int32  // The biYPelsPerMeter field; 0 if not available
+
+	// "none", "rle4", "rle8", "jpeg", "png", "huffman1d", "rle24", "unknown"
+	compressionType string
+
+	palNumEntries    int
+	palBytesPerEntry int
+	palSizeInBytes   int
+
+	// The R, G, B, and A masks, from the infoheader or BITFIELDS segment.
+	// Only meaningful for BI_BITFIELDS and BI_ALPHABITFIELDS images.
+	masks [4]uint32
+
+	palPos int64 // The file position of the color table
+
+	// The headers, and the location of the bits, as read. Set for Parse.
+	fileHeader *FileHeader
+	infoHeader InfoHeader
+	bits       *BitmapBits
+
+	hasBitfieldsSegment  bool
+	bitfieldsSegmentSize int64
+	hasProfile           bool
+	profileIsLinked      bool
+	profileOffset        int64
+	profileSize          int64
+	// An ICC profile was found in the gap before the bitmap bits, as written
+	// by Photoshop.
+	hasNonStandardProfile bool
+	isCompressed          bool
+	topDown               bool
+
+	// The number of bytes from the start of one row to the start of the next
+	// row, and the number of bytes in the whole image (or the number of bytes
+	// there would be if the image were not compressed).
+	rowStride      int64
+	calculatedSize int64
+
+	actualBitsSize int64 // 0 = unknown
+	rleRowsDecoded int   // The number of rows in the RLE data
+
+	// The blocks of unused bytes found so far
+	gaps []gapInfo_type
+
+	// The number of bytes that belong to a known section (including the
+	// gap before the bitmap bits).
+	accountedBytes int64
+
+	fieldNamePrefix string
+
+	// The text of each warning, as printed by warn.
+	warnings []string
+
+	badColorFlag   bool
+	badColorWarned bool
+	badColorIndex  int
+	badColor_X     int
+	badColor_Y     int
+}
+
+// Return the writer that the output goes to.
+func (ctx *ctx_type) writer() io.Writer {
+	if ctx.out == nil {
+		return os.Stdout
+	}
+	return ctx.out
+}
+
+// A wrapper for fmt.Printf.
+func (ctx *ctx_type) printf(format string, a ...interface{}) (n int, err error) {
+	return ctx.print(fmt.Sprintf(format, a...))
+}
+
+// Print an unformatted string.
+func (ctx *ctx_type) print(s string) (n int, err error) {
+	if ctx.json != nil {
+		ctx.json.captureText(s)
+	}
+	if ctx.suppressOutput {
+		return 0, nil
+	}
+	if ctx.suppressLine {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			return 0, nil
+		}
+		s = s[i+1:]
+		ctx.suppressLine = false
+		ctx.pendingSpecRef = ""
+		ctx.pendingRawBytes = ""
+		if s == "" {
+			return 0, nil
+		}
+	}
+	if ctx.pendingSpecRef != "" || ctx.pendingRawBytes != "" {
+		// Insert the reference and raw bytes at the end of the line.
+		i := strings.IndexByte(s, '\n')
+		if i >= 0 {
+			var suffix string
+			if ctx.pendingRawBytes != "" {
+				suffix += "  [raw: " + ctx.pendingRawBytes + "]"
+			}
+			if ctx.pendingSpecRef != "" {
+				suffix += "  [" + ctx.pendingSpecRef + "]"
+			}
+			s = s[:i] + suffix + s[i:]
+			ctx.pendingSpecRef = ""
+			ctx.pendingRawBytes = ""
+		}
+	}
+	if ctx.table != nil {
+		ctx.table.capture(s)
+		return len(s), nil
+	}
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		ctx.column = utf8.RuneCountInString(s[i+1:])
+	} else {
+		ctx.column += utf8.RuneCountInString(s)
+	}
+	return ctx.emit(s)
+}
+
+// Write s to the output, or record it. It is not affected by any of the
+// settings that hide output.
+func (ctx *ctx_type) emit(s string) (n int, err error) {
+	if ctx.recorder != nil {
+		ctx.recorder.write(s)
+		return len(s), nil
+	}
+	return io.WriteString(ctx.writer(), s)
+}
+
+// Print a warning message, and record it in the validity score.
+func (ctx *ctx_type) warn(category string, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	ctx.score.deduct(category)
+	ctx.warnings = append(ctx.warnings, msg)
+	if ctx.suppressOutput && ctx.alwaysShowWarnings {
+		ctx.emit("Warning: " + msg + "\n")
+		return
+	}
+	ctx.printProblem("Warning: " + msg + "\n")
+}
+
+// Print a warning or error line. It is printed even if the current line is
+// being hidden (see suppressLine), since warnings are always shown. The rest
+// of the hidden line stays hidden.
+func (ctx *ctx_type) printProblem(s string) {
+	if !ctx.suppressLine {
+		ctx.print(s)
+		return
+	}
+	// The hidden line's spec reference and raw bytes are discarded with it.
+	ctx.pendingSpecRef = ""
+	ctx.pendingRawBytes = ""
+	ctx.suppressLine = false
+	ctx.print(s)
+	ctx.suppressLine = true
+}
+
+// Report a problem that prevents the pixels from being inspected. It is
+// recorded with the warnings.
+func (ctx *ctx_type) reportError(category string, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	ctx.score.deduct(category)
+	ctx.warnings = append(ctx.warnings, "Error: "+msg)
+	ctx.printProblem("Error: " + msg + "\n")
+}
+
+// Print pixel values, unless they are being suppressed.
+func (ctx *ctx_type) pixPrintf(format string, a ...interface{}) {
+	if ctx.rowChecksumsOnly {
+		return
+	}
+	ctx.printf(format, a...)
+}
+
+func (ctx *ctx_type) pixPrint(s string) {
+	if ctx.rowChecksumsOnly {
+		return
+	}
+	ctx.print(s)
+}
+
+// If pixelOffsets is set, print the file offset of a pixel, before the
+// pixel's value. For pixels smaller than a byte, bit is the position of the
+// pixel's lowest bit in the byte (0 = least significant); otherwise it is -1.
+func (ctx *ctx_type) printPixelOffset(offset int64, bit int) {
+	if !ctx.pixelOffsets {
+		return
+	}
+	if bit < 0 {
+		ctx.pixPrintf(" @0x%x:", offset)
+	} else {
+		ctx.pixPrintf(" @0x%x.%d: ", offset, bit)
+	}
+}
+
+// The names of the sections that can be used with -skip-section.
+var sectionNames = []string{"fileheader", "infoheader", "bitfields", "colortable",
+	"bits", "profile"}
+
+// A set of section names, usable as a repeatable command-line flag.
+type sectionList_type map[string]bool
+
+func (sl sectionList_type) String() string {
+	var names []string
+	for _, name := range sectionNames {
+		if sl[name] {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// How -decode-rle shows the pixels of RLE runs.
+type decodeRLEMode_type string
+
+const (
+	decodeRLENone    decodeRLEMode_type = ""
+	decodeRLEReplace decodeRLEMode_type = "replace" // Instead of the runs
+	decodeRLEInline  decodeRLEMode_type = "inline"  // After each run
+)
+
+func (m *decodeRLEMode_type) String() string {
+	return string(*m)
+}
+
+// -decode-rle by itself means -decode-rle=replace.
+func (m *decodeRLEMode_type) IsBoolFlag() bool {
+	return true
+}
+
+func (m *decodeRLEMode_type) Set(s string) error {
+	switch s {
+	case "true", "replace":
+		*m = decodeRLEReplace
+	case "false":
+		*m = decodeRLENone
+	case "inline":
+		*m = decodeRLEInline
+	default:
+		return errors.New("Unknown mode (valid modes: replace, inline)")
+	}
+	return nil
+}
+
+func isSectionName(name string) bool {
+	for _, v := range sectionNames {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (sl sectionList_type) Set(name string) error {
+	if isSectionName(name) {
+		sl[name] = true
+		return nil
+	}
+	return errors.New("Unknown section name (valid names: " +
+		strings.Join(sectionNames, ", ") + ")")
+}
+
+// Called at the start of a section. If the user asked to show only one
+// section, and this is not it, the output is discarded until the returned
+// function is called. name is "" for output that is not part of any section.
+// Usage: defer enterSection(ctx, name)()
+func enterSection(ctx *ctx_type, name string) func() {
+	saveSuppressOutput := ctx.suppressOutput
+	saveSection := ctx.section
+	ctx.section = name
+	if ctx.showOnly != "" && ctx.showOnly != name {
+		ctx.suppressOutput = true
+	}
+	return func() {
+		ctx.suppressOutput = saveSuppressOutput
+		ctx.section = saveSection
+	}
+}
+
+// If the user asked to skip the named section, print a note saying so, and
+// return true.
+func skipSection(ctx *ctx_type, name string) bool {
+	if !ctx.skipSections[name] {
+		return false
+	}
+	startLine(ctx, 0)
+	ctx.printf("(Section %s skipped by user request)\n", name)
+	return true
+}
+
+// Format the file offset at the start of a line.
+func formatLineOffset(pos int64) string {
+	return fmt.Sprintf("%7d: ", pos)
+}
+
+func startLineAbsolute(ctx *ctx_type, pos int64) {
+	if ctx.recorder != nil {
+		defer ctx.recorder.beginLine(pos)()
+	}
+	ctx.print(formatLineOffset(pos))
+}
+
+func startLine(ctx *ctx_type, offset int64) {
+	startLineAbsolute(ctx, ctx.pos+offset)
+}
+
+// Print a section heading, including the range of file offsets the section
+// occupies, starting at ctx.pos. If size is 0, the range is not printed.
+func printSectionBanner(ctx *ctx_type, name string, size int64) {
+	startLine(ctx, 0)
+	if size > 0 {
+		ctx.printf("----- %s (bytes %d–%d) -----\n", name, ctx.pos, ctx.pos+size-1)
+	} else {
+		ctx.printf("----- %s -----\n", name)
+	}
+}
+
+func translateFieldName(ctx *ctx_type, origFieldName string) string {
+	newFieldName := origFieldName
+
+	if ctx.bmpVerID == "os2v1" || ctx.bmpVerID == "os2v2" {
+		switch origFieldName {
+		case "bfSize":
+			newFieldName = "cbSize"
+		case "bfReserved1":
+			newFieldName = "xHotspot"
+		case "bfReserved2":
+			newFieldName = "yHotspot"
+		case "bfOffBits":
+			newFieldName = "offBits"
+
+		// OS/2 2.0 images have a separate "Units" field, so it would be wrong
+		// to label these fields as "per meter".
+		case "XPelsPerMeter":
+			newFieldName = "XResolution"
+		case "YPelsPerMeter":
+			newFieldName = "YResolution"
+		}
+		return newFieldName
+	}
+
+	return ctx.fieldNamePrefix + newFieldName
+}
+
+// Look up the documentation reference for a field. newFieldName is the
+// translated field name.
+func getFieldSpecRef(ctx *ctx_type, newFieldName string) string {
+	if ctx.bmpVerID == "os2v1" || ctx.bmpVerID == "os2v2" {
+		return fieldSpecRefs[ctx.bmpVerID+":"+newFieldName]
+	}
+	return fieldSpecRefs[newFieldName]
+}
+
+func (ctx *ctx_type) printFieldName(fieldName string) {
+	newFieldName := translateFieldName(ctx, fieldName)
+	if ctx.recorder != nil {
+		ctx.recorder.setField(newFieldName)
+	}
+	ctx.print(newFieldName + ": ")
+	if ctx.specRefs {
+		ctx.pendingSpecRef = getFieldSpecRef(ctx, newFieldName)
+	}
+}
+
+// The key in fieldDefaults for the field named fieldName (as passed to
+// pfxPrintf).
+func defaultValueKey(fieldName string) string {
+	if strings.HasPrefix(fieldName, "bf") {
+		return fieldName
+	}
+	return "bi" + fieldName
+}
+
+// If -diff-from-defaults is in effect, and the field's value (the first of
+// the values to be printed) is its default value, arrange for its line to
+// be hidden.
+func (ctx *ctx_type) checkDefaultValue(fieldName string, a []interface{}) {
+	if !ctx.diffFromDefaults || len(a) < 1 {
+		return
+	}
+	defaultValue, ok := fieldDefaults[defaultValueKey(fieldName)]
+	if ok && a[0] == defaultValue {
+		ctx.suppressLine = true
+	}
+}
+
+// Start a new line, using the appropriate field name, with the "bi" (etc.) prefix.
+func (ctx *ctx_type) pfxPrintf(offset int64, fieldName string, format string, a ...interface{}) {
+	ctx.checkDefaultValue(fieldName, a)
+	startLine(ctx, offset)
+	ctx.printFieldName(fieldName)
+	ctx.beginJSONField(ctx.pos+offset, fieldName, a)
+	ctx.printf(format, a...)
+}
+
+// Like pfxPrintf, but if rawBytes is set, also show the size bytes that the
+// field's value was decoded from.
+func (ctx *ctx_type) pfxPrintfWithRaw(offset int64, size int, fieldName string, format string, a ...interface{}) {
+	d := ctx.file.bytes(ctx.pos+offset, ctx.pos+offset+int64(size))
+	if ctx.rawBytes {
+		ctx.pendingRawBytes = fmt.Sprintf("% x", d)
+	}
+	if ctx.json != nil {
+		ctx.json.setPendingValue(d)
+	}
+	ctx.pfxPrintf(offset, fieldName, format, a...)
+}
+
+// DWORD is an unsigned 32-bit little-endian integer.
+func getDWORD(d []byte) uint32 {
+	return binary.LittleEndian.Uint32(d[0:4])
+}
+
+// WORD is an unsigned 16-bit little-endian integer.
+func getWORD(d []byte) uint16 {
+	return binary.LittleEndian.Uint16(d[0:2])
+}
+
+// LONG is a signed 32-bit little-endian integer.
+func getLONG(d []byte) int32 {
+	return int32(getDWORD(d))
+}
+
+func getFloat16dot16(d []byte) float64 {
+	return float64(getDWORD(d)) / 65536.0
+}
+
+func getFloat2dot30(d []byte) float64 {
+	return float64(getDWORD(d)) / 1073741824.0
+}
+
+func detectVersion(ctx *ctx_type) {
+	var infoHeaderSize uint32
+	var bitCount uint16
+	var compression uint32
+	var fsize uint32
+	var os2CmprFlag bool
+
+	if ctx.file.size < 18 {
+		return
+	}
+	// The position of the infoheader.
+	ihPos := ctx.pos + 14
+	if ctx.noFileheader {
+		ihPos = ctx.pos
+	} else {
+		fsize = getDWORD(ctx.file.bytes(ctx.pos+2, ctx.pos+6))
+	}
+	infoHeaderSize = getDWORD(ctx.file.bytes(ihPos, ihPos+4))
+	if ctx.fileSize-ihPos >= 16 {
+		bitCount = getWORD(ctx.file.bytes(ihPos+14, ihPos+16))
+	}
+	if ctx.fileSize-ihPos >= 20 {
+		compression = getDWORD(ctx.file.bytes(ihPos+16, ihPos+20))
+	}
+
+	if (compression == 3 && bitCount == 1) || (compression == 4 && bitCount == 24) {
+		os2CmprFlag = true
+	}
+
+	if infoHeaderSize == 12 && fsize == 14+infoHeaderSize {
+		ctx.bmpVerID = "os2v1"
+	} else if infoHeaderSize == 12 {
+		ctx.bmpVerID = "winv2"
+	} else if (os2CmprFlag || fsize == 14+infoHeaderSize) &&
+		infoHeaderSize >= 16 && infoHeaderSize <= 64 {
+		ctx.bmpVerID = "os2v2"
+	} else if infoHeaderSize == 40 {
+		ctx.bmpVerID = "winv3"
+		if bitCount == 2 {
+			ctx.bmpVerName = "Windows CE BMP"
+			ctx.isWindowsCE = true
+		}
+	} else if bitCount == 2 && infoHeaderSize > 40 && infoHeaderSize != 52 &&
+		infoHeaderSize != 56 && infoHeaderSize != 108 && infoHeaderSize != 124 {
+		// A 2-bit image can only be a Windows CE BMP, so assume a larger
+		// header is the Windows CE extended header.
+		ctx.bmpVerID = "wince"
+		ctx.isWindowsCE = true
+	} else if infoHeaderSize == 52 {
+		ctx.bmpVerID = "52"
+	} else if infoHeaderSize == 56 {
+		ctx.bmpVerID = "56"
+	} else if infoHeaderSize > 16 && infoHeaderSize < 40 {
+		// Probably a truncated BITMAPINFOHEADER. (OS/2 v2 headers may also
+		// be truncated, but are usually 16 or 64 bytes.)
+		ctx.bmpVerID = "partial"
+	} else if infoHeaderSize >= 16 && infoHeaderSize <= 64 {
+		ctx.bmpVerID = "os2v2"
+	} else if infoHeaderSize == 108 {
+		ctx.bmpVerID = "winv4"
+	} else if infoHeaderSize == 124 {
+		ctx.bmpVerID = "winv5"
+	} else {
+		ctx.bmpVerID = "unknown"
+	}
+
+	// Set bmpVerName based on the ID, if it's not already set.
+	if ctx.bmpVerName == "" {
+		ctx.bmpVerName = versionIDToName[ctx.bmpVerID]
+	}
+}
+
+// Functions named "inspect*" are passed a slice to read from,
+// and do not modify ctx.pos.
+// Functions named "read*" read directly from ctx.file, and
+// are responsible for updating ctx.pos.
+
+func inspectFileheader(ctx *ctx_type, d []byte) error {
+	defer enterSection(ctx, "fileheader")()
+
+	// We need the information in the fileheader, so if it's being skipped,
+	// read it without displaying it.
+	if skipSection(ctx, "fileheader") {
+		ctx.suppressOutput = true
+		defer func() { ctx.suppressOutput = false }()
+	}
+
+	printSectionBanner(ctx, "FILEHEADER", int64(len(d)))
+	defer beginHeaderTable(ctx)()
+
+	ctx.fileType = string(d[0:2])
+	ctx.setJSONValue(ctx.fileType)
+	ctx.pfxPrintf(0, "bfType", "0x%02x 0x%02x (%+q)", d[0], d[1], ctx.fileType)
+
+	fileTypeName := fileTypeNames[ctx.fileType]
+	if fileTypeName == "" {
+		ctx.printf("\n")
+		return errors.New("Not a BMP file")
+	}
+	ctx.printf(" = %s\n", fileTypeName)
+	switch ctx.fileType {
+	case "BM":
+	case "IC", "PT", "CI", "CP":
+		// The bitmap is a 1-bit AND mask and XOR mask, one above the
+		// other. For CI and CP, another fileheader follows the color table,
+		// for the color image.
+		startLine(ctx, 0)
+		if ctx.iconColorImage {
+			ctx.print("(Icon/pointer: color image)\n")
+		} else {
+			ctx.print("(Icon/pointer: the bitmap holds the AND and XOR masks, each half the height)\n")
+		}
+	default:
+		return errors.New("File type not supported")
+	}
+
+	detectVersion(ctx)
+	printVersionDetected(ctx)
+
+	bfSize := getDWORD(d[2:6])
+	ctx.pfxPrintf(2, "bfSize", "%v\n", bfSize)
+	// The Size field is usually is set to the file size. But in OS/2 BMPs
+	// it can be set to the fileHeader size + infoHeader size, so don't warn
+	// about that. Some Windows CE BMPs set it to 0.
+	if bfSize == 0 {
+		if ctx.isWindowsCE {
+			startLine(ctx, 2)
+			ctx.printf("(bfSize is 0: Windows CE convention - actual file size is %v bytes)\n",
+				ctx.fileSize)
+		} else {
+			ctx.warn("bfSize", "bfSize is 0 (Windows CE convention - actual file size is %v bytes)",
+				ctx.fileSize)
+		}
+	} else if (int64(bfSize) != ctx.fileSize) && (bfSize != 14+ctx.infoHeaderSize) {
+		ctx.warn("bfSize", "Reported file size (%v) does not equal actual file size (%v)",
+			bfSize, ctx.fileSize)
+	}
+
+	// In OS/2 BMPs, these are hotspot fields, which may be nonzero.
+	isOS2 := ctx.bmpVerID == "os2v1" || ctx.bmpVerID == "os2v2"
+
+	bfReserved1 := getWORD(d[6:8])
+	ctx.pfxPrintf(6, "bfReserved1", "%v\n", bfReserved1)
+	if bfReserved1 != 0 && !isOS2 {
+		ctx.warn("reserved", "bfReserved1 is nonzero")
+	}
+
+	bfReserved2 := getWORD(d[8:10])
+	ctx.pfxPrintf(8, "bfReserved2", "%v\n", bfReserved2)
+	if bfReserved2 != 0 && !isOS2 {
+		ctx.warn("reserved", "bfReserved2 is nonzero")
+	}
+
+	if !isOS2 {
+		detectThumbnail(ctx, getDWORD(d[6:10]))
+	}
+
+	ctx.bfOffBits = getDWORD(d[10:14])
+	ctx.pfxPrintf(10, "bfOffBits", "%v\n", ctx.bfOffBits)
+
+	ctx.fileHeader = &FileHeader{Type: ctx.fileType, Size: bfSize,
+		Reserved1: bfReserved1, Reserved2: bfReserved2, OffBits: ctx.bfOffBits}
+	return nil
+}
+
+// A width or height of 0 is never valid. Unlike other problems with the
+// dimensions, it's reported as an error, though the rest of the headers are
+// still inspected.
+func reportZeroDimension(ctx *ctx_type, name string) {
+	ctx.reportError("dimensions", "%s is 0 (zero-dimension image)", name)
+	ctx.printPixels = false
+	ctx.calculatedSize = 0
+}
+
+func inspectInfoheaderOS2(ctx *ctx_type, d []byte) error {
+
+	bcWidth := getWORD(d[4:6])
+	ctx.pfxPrintfWithRaw(4, 2, "Width", "%v\n", bcWidth)
+	ctx.imgWidth = int(bcWidth)
+	if bcWidth == 0 {
+		reportZeroDimension(ctx, "Width")
+	}
+
+	bcHeight := getWORD(d[6:8])
+	ctx.pfxPrintfWithRaw(6, 2, "Height", "%v\n", bcHeight)
+	ctx.imgHeight = int(bcHeight)
+	if bcHeight == 0 {
+		reportZeroDimension(ctx, "Height")
+	}
+	printAspectRatio(ctx, 6)
+
+	bcPlanes := getWORD(d[8:10])
+	ctx.pfxPrintfWithRaw(8, 2, "Planes", "%v\n", bcPlanes)
+
+	bcBitCount := getWORD(d[10:12])
+	ctx.pfxPrintfWithRaw(10, 2, "BitCount", "%v\n", bcBitCount)
+	ctx.bitCount = int(bcBitCount)
+
+	ctx.palBytesPerEntry = 3
+
+	if ctx.noFileheader {
+		// There's no bfOffBits field to check.
+		if bcBitCount <= 8 {
+			ctx.palNumEntries = 1 << bcBitCount
+		}
+		return nil
+	}
+
+	// The header has no fields that could explain a gap after the color
+	// table, so the bits are expected to immediately follow it.
+	var overlapWarned bool
+	if bcBitCount <= 8 {
+		ctx.palNumEntries = 1 << bcBitCount
+
+		bytesAvailableForPalette := int(ctx.bfOffBits) - (int(ctx.pos) + int(ctx.infoHeaderSize))
+		if bytesAvailableForPalette >= 3 && bytesAvailableForPalette < 3*ctx.palNumEntries {
+			ctx.palNumEntries = bytesAvailableForPalette / 3
+			ctx.warn("palette", "Bitmap overlaps color table. Assuming there are only %d colors in color table",
+				ctx.palNumEntries)
+			overlapWarned = true
+		}
+	}
+	if !overlapWarned {
+		expectedOffBits := int(ctx.pos) + int(ctx.infoHeaderSize) + 3*ctx.palNumEntries
+		if int(ctx.bfOffBits) > expectedOffBits && !ctx.inBitmapArray {
+			ctx.warn("header", "For %s, expected bfOffBits=%v but got %v (gap of %v bytes)",
+				ctx.bmpVerName, expectedOffBits, ctx.bfOffBits, int(ctx.bfOffBits)-expectedOffBits)
+		} else if int(ctx.bfOffBits) < expectedOffBits {
+			ctx.warn("header", "For %s, expected bfOffBits=%v but got %v (overlap of %v bytes)",
+				ctx.bmpVerName, expectedOffBits, ctx.bfOffBits, expectedOffBits-int(ctx.bfOffBits))
+		}
+	}
+	return nil
+}
+
+func printDotsPerMeter(ctx *ctx_type, n int32) {
+	ctx.printf("%v", n)
+	if n != 0 {
+		ctx.printf(" (%.2f dpi)", float64(n)*0.0254)
+	}
+	ctx.print("\n")
+}
+
+// Based on the compressionCode and BMP version, return a description of the
+// compressionCode, and the compression algorithm.
+func getCompressionCodeInfo(ctx *ctx_type) (string, string) {
+	if ctx.compressionCode&bI_SRCPREROTATE != 0 {
+		// Windows Mobile sets this flag in addition to the normal
+		// compression code. Remove it, so that the image can be processed
+		// normally.
+		ctx.hasSrcPrerotate = true
+		ctx.compressionCode &= 0x7fff
+		descr, cmprType := getCompressionCodeInfo(ctx)
+		return "BI_SRCPREROTATE | " + descr, cmprType
+	}
+
+	switch ctx.compressionCode {
+	case bI_RGB:
+		return "BI_RGB (uncompressed)", "none"
+	case bI_RLE8:
+		return "BI_RLE8", "rle8"
+	case bI_RLE4:
+		return "BI_RLE4", "rle4"
+	case 3:
+		if ctx.bmpVerID == "os2v2" {
+			return "Huffman 1D", "huffman1d"
+		}
+		return "BI_BITFIELDS (uncompressed)", "none"
+	case 4:
+		if ctx.bmpVerID == "os2v2" {
+			return "RLE24", "rle24"
+		}
+		return "BI_JPEG", "jpeg"
+	case bI_PNG:
+		return "BI_PNG", "png"
+	case bI_ALPHABITFIELDS:
+		return "BI_ALPHABITFIELDS (uncompressed)", "none"
+	}
+	return "(unrecognized)", "unknown"
+}
+
+// A BITMAPINFOHEADER that is less than 40 bytes. inspectInfoheaderV3 skips
+// the fields that aren't there.
+func inspectInfoheaderPartial(ctx *ctx_type, d []byte) error {
+	startLine(ctx, 0)
+	ctx.printf("(Non-standard infoheader size: %v bytes - printing available fields only)\n",
+		len(d))
+	ctx.warn("header", "Some fields may be missing from this header")
+	return inspectInfoheaderV3(ctx, d)
+}
+
+// The Windows 2.x header has the same fields as the OS/2 v1 header. (The
+// BitCount is checked by checkBitCount, which only allows the values that
+// Windows 2.x supported: 1, 4, 8, and 24.)
+func inspectInfoheaderWinV2(ctx *ctx_type, d []byte) error {
+	startLine(ctx, 0)
+	ctx.print("(Windows 2.x BITMAPCOREHEADER format)\n")
+	return inspectInfoheaderOS2(ctx, d)
+}
+
+// len(d) is assumed to be at least 16.
+func inspectInfoheaderV3(ctx *ctx_type, d []byte) error {
+	var biXPelsPerMeter int32
+	var biYPelsPerMeter int32
+	var compressionCodeDescr string = "none"
+	var biClrUsed uint32
+	var biClrImportant uint32
+
+	biWidth := getLONG(d[4:8])
+	ctx.pfxPrintfWithRaw(4, 4, "Width", "%v\n", biWidth)
+	ctx.imgWidth = int(biWidth)
+	if biWidth == 0 {
+		reportZeroDimension(ctx, "Width")
+	} else if biWidth < 0 {
+		ctx.warn("dimensions", "Width is negative (%v) - invalid (note: negative height is legal for top-down)",
+			biWidth)
+		ctx.printPixels = false
+	}
+
+	biHeight := getLONG(d[8:12])
+	ctx.pfxPrintfWithRaw(8, 4, "Height", "%v", biHeight)
+	if biHeight < 0 {
+		ctx.topDown = true
+		ctx.imgHeight = int(-biHeight)
+		ctx.printf(" (%v pixels, top-down)", ctx.imgHeight)
+	} else {
+		ctx.imgHeight = int(biHeight)
+	}
+	ctx.print("\n")
+	if biHeight == 0 {
+		reportZeroDimension(ctx, "Height")
+	} else if ctx.imgHeight < 1 {
+		ctx.warn("dimensions", "Bad height")
+		ctx.printPixels = false
+	}
+	printAspectRatio(ctx, 8)
+
+	biPlanes := getWORD(d[12:14])
+	ctx.pfxPrintfWithRaw(12, 2, "Planes", "%v\n", biPlanes)
+	if biPlanes == 0 {
+		return fmt.Errorf("%s is 0 (invalid)", translateFieldName(ctx, "Planes"))
+	} else if biPlanes != 1 {
+		ctx.warn("header", "%s is %v (required to be 1; %v color planes might indicate "+
+			"a non-standard multi-plane format)", translateFieldName(ctx, "Planes"),
+			biPlanes, biPlanes)
+	}
+
+	biBitCount := getWORD(d[14:16])
+	ctx.pfxPrintfWithRaw(14, 2, "BitCount", "%v\n", biBitCount)
+	ctx.bitCount = int(biBitCount)
+	if int(biBitCount)*int(biPlanes) > 32 {
+		ctx.warn("header", "%s * %s = %v (exceeds 32-bit pixel limit)",
+			translateFieldName(ctx, "BitCount"), translateFieldName(ctx, "Planes"),
+			int(biBitCount)*int(biPlanes))
+	}
+
+	if len(d) >= 20 {
+		ctx.compressionCode = getDWORD(d[16:20])
+		ctx.pfxPrintfWithRaw(16, 4, "Compression", "%v", ctx.compressionCode)
+
+		// This may modify ctx.compressionCode.
+		compressionCodeDescr, ctx.compressionType = getCompressionCodeInfo(ctx)
+
+		ctx.printf(" = %v\n", compressionCodeDescr)
+
+		if ctx.hasSrcPrerotate {
+			ctx.warn("compression", "BI_SRCPREROTATE flag detected (Windows Mobile pre-rotation)")
+			startLine(ctx, 16)
+			ctx.print("(The image data may be rotated 90 degrees, and the width and height may be transposed)\n")
+		}
+
+		ctx.isCompressed = ctx.compressionType != "none"
+
+		if ctx.isCompressed && ctx.topDown {
+			switch ctx.compressionType {
+			case "rle4", "rle8", "rle24":
+				// The RLE decoder assumes the image is bottom-up.
+				ctx.printPixels = false
+				ctx.reportError("compression", "Top-down images cannot use RLE compression (see BMP spec)")
+			case "jpeg", "png", "unknown":
+				// The sign of the height does not tell us anything about
+				// embedded JPEG/PNG images.
+			default:
+				ctx.printPixels = false
+				ctx.warn("compression", "Compressed images may not be top-down")
+			}
+		}
+
+		if ctx.compressionCode == bI_BITFIELDS && ctx.bmpVerID == "winv3" {
+			ctx.hasBitfieldsSegment = true
+			ctx.bitfieldsSegmentSize = 12
+		} else if ctx.compressionCode == bI_ALPHABITFIELDS && ctx.bmpVerID == "winv3" {
+			ctx.hasBitfieldsSegment = true
+			ctx.bitfieldsSegmentSize = 16
+		}
+	}
+
+	if len(d) >= 24 {
+		ctx.sizeImage = getDWORD(d[20:24])
+		ctx.pfxPrintfWithRaw(20, 4, "SizeImage", "%v\n", ctx.sizeImage)
+	}
+
+	// (For JPEG and PNG, this is checked by checkEmbeddedImage.)
+	if ctx.sizeImage == 0 && ctx.isCompressed &&
+		ctx.compressionType != "jpeg" && ctx.compressionType != "png" {
+		ctx.warn("header", "SizeImage is required for compressed images")
+	}
+
+	if len(d) >= 28 {
+		biXPelsPerMeter = getLONG(d[24:28])
+		ctx.xPelsPerMeter = biXPelsPerMeter
+		ctx.pfxPrintfWithRaw(24, 4, "XPelsPerMeter", "")
+		printDotsPerMeter(ctx, biXPelsPerMeter)
+	}
+
+	if len(d) >= 32 {
+		biYPelsPerMeter = getLONG(d[28:32])
+		ctx.yPelsPerMeter = biYPelsPerMeter
+		ctx.pfxPrintfWithRaw(28, 4, "YPelsPerMeter", "")
+		printDotsPerMeter(ctx, biYPelsPerMeter)
+		printPhysicalAspectRatio(ctx, 28, biXPelsPerMeter, biYPelsPerMeter)
+	}
+
+	if len(d) >= 36 {
+		biClrUsed = getDWORD(d[32:36])
+		ctx.pfxPrintfWithRaw(32, 4, "ClrUsed", "%v\n", biClrUsed)
+
+		if biClrUsed > 100000 {
+			return errors.New("Unreasonable color table size")
+		}
+	}
+
+	ctx.palBytesPerEntry = 4
+
+	if biBitCount > 0 && biBitCount <= 8 {
+		if biClrUsed == 0 {
+			ctx.palNumEntries = 1 << uint(biBitCount)
+		} else {
+			ctx.palNumEntries = int(biClrUsed)
+		}
+	} else {
+		ctx.palNumEntries = int(biClrUsed)
+	}
+
+	if biBitCount > 0 && biBitCount <= 8 {
+		maxPaletteEntries := 1 << uint(biBitCount)
+		if ctx.palNumEntries > maxPaletteEntries {
+			ctx.warn("palette", "Palette has %v entries but bit depth only supports max %v entries",
+				ctx.palNumEntries, maxPaletteEntries)
+			startLine(ctx, 32)
+			ctx.printf("(%v unnecessary palette entries = %v bytes wasted)\n",
+				ctx.palNumEntries-maxPaletteEntries,
+				(ctx.palNumEntries-maxPaletteEntries)*ctx.palBytesPerEntry)
+		}
+	}
+
+	if len(d) >= 40 {
+		biClrImportant = getDWORD(d[36:40])
+		ctx.pfxPrintfWithRaw(36, 4, "ClrImportant", "%v", biClrImportant)
+		if biClrImportant == 0 {
+			ctx.print(" (all colors are important)")
+		} else if int64(biClrImportant) == int64(ctx.palNumEntries) {
+			ctx.print(" (all colors marked important)")
+		} else if int64(biClrImportant) < int64(ctx.palNumEntries) {
+			ctx.printf(" (only first %v palette entries are required for display)",
+				biClrImportant)
+		}
+		ctx.print("\n")
+
+		if int64(biClrImportant) > int64(ctx.palNumEntries) {
+			ctx.warn("header", "ClrImportant (%v) exceeds palette size (%v)",
+				biClrImportant, ctx.palNumEntries)
+		}
+	}
+
+	return nil
+}
+
+func formatCIEXYZ(ctx *ctx_type, d []byte) string {
+	x := getFloat2dot30(d[0:4])
+	y := getFloat2dot30(d[4:8])
+	z := getFloat2dot30(d[8:12])
+	return fmt.Sprintf("X:%.8f Y:%.8f Z:%.8f", x, y, z)
+}
+
+func inspectCIEXYZTRIPLE(ctx *ctx_type, d []byte, offset int64) {
+	ctx.pfxPrintfWithRaw(offset, 12, "Endpoints", "Red:   %s\n", formatCIEXYZ(ctx, d[0:12]))
+	ctx.pfxPrintfWithRaw(offset+12, 12, "Endpoints", "Green: %s\n", formatCIEXYZ(ctx, d[12:24]))
+	ctx.pfxPrintfWithRaw(offset+24, 12, "Endpoints", "Blue:  %s\n", formatCIEXYZ(ctx, d[24:36]))
+}
+
+func csTypeIsValid(ctx *ctx_type, csType uint32) bool {
+	if ctx.bmpVerID == "winv4" {
+		if csType == lCS_CALIBRATED_RGB {
+			return true
+		}
+	} else if ctx.bmpVerID == "winv5" {
+		switch csType {
+		case lCS_CALIBRATED_RGB, lCS_sRGB, lCS_WINDOWS_COLOR_SPACE,
+			pROFILE_LINKED, pROFILE_EMBEDDED:
+			return true
+		}
+	}
+	return false
+}
+
+// Flags in the FlipFlags field of the Windows CE extended infoheader.
+const (
+	wINCE_FLIP_HORIZONTAL = 0x1
+	wINCE_FLIP_VERTICAL   = 0x2
+)
+
+// The Windows CE extended infoheader: a BITMAPINFOHEADER, followed by
+// fields used by some Windows CE devices. These fields are not in the
+// desktop Windows documentation, so the interpretation is tentative.
+func inspectInfoheaderWinCE(ctx *ctx_type, d []byte) error {
+	err := inspectInfoheaderV3(ctx, d[0:40])
+	if err != nil {
+		return err
+	}
+
+	if len(d) >= 44 {
+		usageCount := getDWORD(d[40:44])
+		ctx.pfxPrintfWithRaw(40, 4, "UsageCount", "%v\n", usageCount)
+	}
+
+	if len(d) >= 48 {
+		flipFlags := getDWORD(d[44:48])
+		ctx.pfxPrintfWithRaw(44, 4, "FlipFlags", "0x%x", flipFlags)
+		var names []string
+		if flipFlags&wINCE_FLIP_HORIZONTAL != 0 {
+			names = append(names, "horizontal flip")
+		}
+		if flipFlags&wINCE_FLIP_VERTICAL != 0 {
+			names = append(names, "vertical flip")
+		}
+		if flipFlags&^(wINCE_FLIP_HORIZONTAL|wINCE_FLIP_VERTICAL) != 0 {
+			names = append(names, "unknown flags")
+		}
+		if len(names) == 0 {
+			names = append(names, "no flip")
+		}
+		ctx.printf(" (%s)\n", strings.Join(names, ", "))
+	}
+
+	if len(d) > 48 {
+		startLine(ctx, 48)
+		ctx.printf("(%v bytes of unknown Windows CE extension fields)\n", len(d)-48)
+	}
+	return nil
+}
+
+func inspectInfoheaderOS2V2(ctx *ctx_type, d []byte) error {
+	var err error
+	var tmpui16 uint16
+	var tmpui32 uint32
+
+	err = inspectInfoheaderV3(ctx, d[0:])
+	if err != nil {
+		return err
+	}
+
+	if len(d) < 42 {
+		return nil
+	}
+	units := getWORD(d[40:42])
+	ctx.pfxPrintfWithRaw(40, 2, "Units", "%d\n", units)
+
+	if len(d) < 44 {
+		return nil
+	}
+	tmpui16 = getWORD(d[42:44])
+	ctx.pfxPrintfWithRaw(42, 2, "Reserved", "%d\n", tmpui16)
+	if len(d) < 46 {
+		return nil
+	}
+	tmpui16 = getWORD(d[44:46])
+	ctx.pfxPrintfWithRaw(44, 2, "Recording", "%d", tmpui16)
+	if name, ok := os2RecordingNames[tmpui16]; ok {
+		ctx.printf(" = %s", name)
+	}
+	ctx.print("\n")
+	if tmpui16 == 1 {
+		startLine(ctx, 44)
+		ctx.print("(Top-down storage)\n")
+		if !ctx.topDown {
+			ctx.warn("header", "Recording field indicates top-down storage, but the height is positive")
+		}
+	} else if tmpui16 != 0 {
+		ctx.warn("header", "Unknown Recording value")
+	}
+	if len(d) < 48 {
+		return nil
+	}
+	tmpui16 = getWORD(d[46:48])
+	ctx.pfxPrintfWithRaw(46, 2, "Rendering", "%d", tmpui16)
+	renderingName, ok := os2RenderingNames[tmpui16]
+	if ok {
+		ctx.printf(" = %s", renderingName)
+	}
+	ctx.print("\n")
+	if !ok {
+		ctx.warn("header", "Unknown Rendering value")
+	}
+
+	if len(d) < 52 {
+		return nil
+	}
+	tmpui32 = getDWORD(d[48:52])
+	ctx.pfxPrintfWithRaw(48, 4, "Size1", "%d\n", tmpui32)
+	if len(d) < 56 {
+		return nil
+	}
+	tmpui32 = getDWORD(d[52:56])
+	ctx.pfxPrintfWithRaw(52, 4, "Size2", "%d\n", tmpui32)
+	if len(d) < 60 {
+		return nil
+	}
+	tmpui32 = getDWORD(d[56:60])
+	ctx.pfxPrintfWithRaw(56, 4, "ColorEncoding", "%d", tmpui32)
+	name, ok := bitmapOS2V2ColorEncodingNames[tmpui32]
+	if ok {
+		ctx.printf(" = %s", name)
+	}
+	ctx.print("\n")
+	if !ok {
+		ctx.warn("header", "Unknown ColorEncoding")
+	} else if tmpui32 == bCCE_PALETTE && ctx.bitCount > 8 {
+		ctx.warn("header", "BCCE_PALETTE is not valid for a %d-bit image", ctx.bitCount)
+	} else if tmpui32 == bCCE_RGB && ctx.bitCount >= 1 && ctx.bitCount <= 8 {
+		startLine(ctx, 56)
+		ctx.print("(The RGB encoding applies to the color table entries)\n")
+	} else if tmpui32 == bCCE_YCrCb {
+		startLine(ctx, 56)
+		ctx.print("(Non-RGB color encoding - color values are YCrCb, not RGB)\n")
+		ctx.warn("header", "bmpinspect does not decode YCrCb colors; they are displayed as stored")
+	}
+	if len(d) < 64 {
+		return nil
+	}
+	tmpui32 = getDWORD(d[60:64])
+	ctx.pfxPrintfWithRaw(60, 4, "Identifier", "%d (0x%x)", tmpui32, tmpui32)
+	name, ok = os2IdentifierNames[tmpui32]
+	if ok {
+		ctx.printf(" = %s", name)
+	} else {
+		ctx.print(" (application-defined)")
+	}
+	ctx.print("\n")
+	return nil
+}
+
+func inspectInfoheaderV4(ctx *ctx_type, d []byte) error {
+	var err error
+	var ok bool
+	var name string
+
+	err = inspectInfoheaderV3(ctx, d[0:40])
+	if err != nil {
+		return err
+	}
+
+	redMask := getDWORD(d[40:44])
+	ctx.pfxPrintfWithRaw(40, 4, "RedMask", "  %s\n", formatMask(ctx, redMask, ansiRed))
+	greenMask := getDWORD(d[44:48])
+	ctx.pfxPrintfWithRaw(44, 4, "GreenMask", "%s\n", formatMask(ctx, greenMask, ansiGreen))
+	blueMask := getDWORD(d[48:52])
+	ctx.pfxPrintfWithRaw(48, 4, "BlueMask", " %s\n", formatMask(ctx, blueMask, ansiBlue))
+	ctx.masks[0], ctx.masks[1], ctx.masks[2] = redMask, greenMask, blueMask
+	isBitfields := ctx.compressionCode == bI_BITFIELDS || ctx.compressionCode == bI_ALPHABITFIELDS
+	if len(d) < 56 {
+		if isBitfields {
+			validateBitfieldMasks(ctx, redMask, greenMask, blueMask, 0)
+		}
+		return nil
+	}
+	alphaMask := getDWORD(d[52:56])
+	ctx.pfxPrintfWithRaw(52, 4, "AlphaMask", "%s\n", formatMask(ctx, alphaMask, ansiCyan))
+	ctx.masks[3] = alphaMask
+	if isBitfields {
+		validateBitfieldMasks(ctx, redMask, greenMask, blueMask, alphaMask)
+	}
+	if ctx.bmpVerID == "56" {
+		if alphaMask&(redMask|greenMask|blueMask) != 0 && !isBitfields {
+			ctx.warn("bitfields", "AlphaMask overlaps the color masks")
+		}
+		startLine(ctx, 52)
+		ctx.print("(This is a BITMAPV3INFOHEADER: R/G/B/A masks only, no color space information)\n")
+	}
+	if len(d) < 108 {
+		return nil
+	}
+
+	csType := getDWORD(d[56:60])
+	ctx.pfxPrintfWithRaw(56, 4, "CSType", "")
+	printFOURCC(ctx, csType)
+	name, ok = csTypeNames[csType]
+	if ok {
+		ctx.printf(" = %s", name)
+	}
+	if !csTypeIsValid(ctx, csType) {
+		ctx.print(" (invalid?)")
+	}
+	ctx.print("\n")
+	if csType == pROFILE_LINKED {
+		ctx.hasProfile = true
+		ctx.profileIsLinked = true
+	} else if csType == pROFILE_EMBEDDED {
+		ctx.hasProfile = true
+	}
+
+	inspectCIEXYZTRIPLE(ctx, d[60:96], 60)
+
+	inspectGamma(ctx, d, 96, "GammaRed", "  ", csType)
+	inspectGamma(ctx, d, 100, "GammaGreen", "", csType)
+	inspectGamma(ctx, d, 104, "GammaBlue", " ", csType)
+
+	if ctx.checkSRGB {
+		checkColorSpaceFields(ctx, d, csType)
+	}
+
+	return nil
+}
+
+// Check that the endpoint and gamma fields are consistent with the color
+// space. They are ignored, and should be 0, unless the color space is
+// LCS_CALIBRATED_RGB.
+func checkColorSpaceFields(ctx *ctx_type, d []byte, csType uint32) {
+	var anyEndpointZero, allEndpointsZero bool
+	allEndpointsZero = true
+	for i := 60; i < 96; i += 4 {
+		if getDWORD(d[i:i+4]) == 0 {
+			anyEndpointZero = true
+		} else {
+			allEndpointsZero = false
+		}
+	}
+
+	switch csType {
+	case lCS_sRGB, lCS_WINDOWS_COLOR_SPACE:
+		csName := csTypeNames[csType]
+		for i, name := range [3]string{"GammaRed", "GammaGreen", "GammaBlue"} {
+			offset := 96 + 4*i
+			if getDWORD(d[offset:offset+4]) != 0 {
+				ctx.warn("header", "%s should be 0 for %s but is %.6f",
+					translateFieldName(ctx, name), csName, getFloat16dot16(d[offset:offset+4]))
+			}
+		}
+		if !allEndpointsZero {
+			ctx.warn("header", "Endpoint fields should be 0 for %s", csName)
+		}
+	case lCS_CALIBRATED_RGB:
+		anyGammaZero := getDWORD(d[96:100]) == 0 || getDWORD(d[100:104]) == 0 ||
+			getDWORD(d[104:108]) == 0
+		if anyGammaZero || anyEndpointZero {
+			ctx.warn("header", "LCS_CALIBRATED_RGB requires non-zero gamma/endpoint values")
+		}
+	}
+}
+
+// Gamma values that have a common name.
+var gammaNames = []struct {
+	gamma float64
+	name  string
+}{
+	{0.45, "encoding gamma ≈ 1/2.2"},
+	{1.0, "linear"},
+	{1.8, "classic Macintosh gamma ≈ 1.8"},
+	{2.0, "gamma ≈ 2.0"},
+	{2.2, "standard sRGB gamma ≈ 2.2"},
+	{2.4, "sRGB/BT.1886 exponent ≈ 2.4"},
+}
+
+// Return a description of a common gamma value, or "" if gamma is not
+// close to one.
+func gammaName(gamma float64) string {
+	for _, g := range gammaNames {
+		if math.Abs(gamma-g.gamma) <= 0.05 {
+			return g.name
+		}
+	}
+	return ""
+}
+
+// Print one of the FXPT16DOT16 gamma fields. pad aligns the value with the
+// other gamma fields.
+func inspectGamma(ctx *ctx_type, d []byte, offset int64, fieldName string,
+	pad string, csType uint32) {
+	rawGamma := getDWORD(d[offset : offset+4])
+	gamma := getFloat16dot16(d[offset : offset+4])
+	ctx.pfxPrintfWithRaw(offset, 4, fieldName, pad+"0x%08x = %.6f", rawGamma, gamma)
+	if name := gammaName(gamma); name != "" {
+		ctx.printf(" (%s)", name)
+	}
+	ctx.print("\n")
+
+	// The gamma fields are only used with LCS_CALIBRATED_RGB.
+	if gamma == 0 && csType == lCS_CALIBRATED_RGB {
+		ctx.warn("header", "%s is 0 (degenerate - linear light would require gamma 1.0)",
+			translateFieldName(ctx, fieldName))
+	}
+}
+
+func inspectInfoheaderV5(ctx *ctx_type, d []byte) error {
+	var err error
+	var ok bool
+	var name string
+
+	err = inspectInfoheaderV4(ctx, d[0:108])
+	if err != nil {
+		return err
+	}
+
+	intent := getDWORD(d[108:112])
+	ctx.pfxPrintfWithRaw(108, 4, "Intent", "%v", intent)
+	name, ok = intentNames[intent]
+	if ok {
+		ctx.printf(" = %s", name)
+	}
+	ctx.print("\n")
+
+	profileData := getDWORD(d[112:116])
+	ctx.pfxPrintfWithRaw(112, 4, "ProfileData", "%v\n", profileData)
+
+	profileSize := getDWORD(d[116:120])
+	ctx.pfxPrintfWithRaw(116, 4, "ProfileSize", "%v\n", profileSize)
+
+	if ctx.hasProfile {
+		ctx.profileOffset = ctx.pos + int64(profileData)
+		ctx.profileSize = int64(profileSize)
+	}
+
+	reserved := getDWORD(d[120:124])
+	ctx.pfxPrintfWithRaw(120, 4, "Reserved", "%v\n", reserved)
+
+	return nil
+}
+
+// ANSI terminal escape sequences.
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiBlue  = "\x1b[34m"
+	ansiCyan  = "\x1b[36m"
+	ansiGray  = "\x1b[90m"
+)
+
+// Format a BITFIELDS mask in binary, with the set bits highlighted in the
+// given color, and the unset bits in gray.
+func colorizedBinaryMask(mask uint32, color string) string {
+	var b strings.Builder
+	var inSetBits bool
+
+	b.WriteString(ansiGray)
+	for i := 31; i >= 0; i-- {
+		bitIsSet := mask&(1<<uint(i)) != 0
+		if bitIsSet && !inSetBits {
+			b.WriteString(color)
+		} else if !bitIsSet && inSetBits {
+			b.WriteString(ansiGray)
+		}
+		inSetBits = bitIsSet
+		if bitIsSet {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	b.WriteString(ansiReset)
+	return b.String()
+}
+
+func formatMask(ctx *ctx_type, mask uint32, color string) string {
+	if ctx.useColor {
+		return colorizedBinaryMask(mask, color)
+	}
+	return fmt.Sprintf("%032b", mask)
+}
+
+func inspectBitfields(ctx *ctx_type, d []byte) error {
+	defer enterSection(ctx, "bitfields")()
+	var colorNames = [4]string{"Red:  ", "Green:", "Blue: ", "Alpha:"}
+	var ansiColors = [4]string{ansiRed, ansiGreen, ansiBlue, ansiCyan}
+
+	for i := 0; i < 4 && i*4 < len(d); i++ {
+		ctx.masks[i] = getDWORD(d[i*4 : i*4+4])
+	}
+
+	if skipSection(ctx, "bitfields") {
+		return nil
+	}
+
+	printSectionBanner(ctx, "BITFIELDS", int64(len(d)))
+
+	for i, v := range colorNames {
+		if i*4 >= len(d) {
+			break
+		}
+		u := getDWORD(d[i*4 : i*4+4])
+		startLine(ctx, int64(i)*4)
+		ctx.printf("%s %s\n", v, formatMask(ctx, u, ansiColors[i]))
+
+	}
+	printChannelDepths(ctx, int64(len(d)))
+	validateBitfieldMasks(ctx, ctx.masks[0], ctx.masks[1], ctx.masks[2], ctx.masks[3])
+	return nil
+}
+
+// Check the masks of a BI_BITFIELDS or BI_ALPHABITFIELDS image for
+// problems: masks that overlap, that don't fit in a pixel, that are
+// missing, or whose bits are not contiguous.
+func validateBitfieldMasks(ctx *ctx_type, red, green, blue, alpha uint32) {
+	var names = [4]string{"Red", "Green", "Blue", "Alpha"}
+	masks := [4]uint32{red, green, blue, alpha}
+
+	for i := 0; i < 4; i++ {
+		for j := i + 1; j < 4; j++ {
+			if masks[i]&masks[j] != 0 {
+				ctx.warn("bitfields", "%sMask and %sMask overlap (bits 0x%08x)",
+					names[i], names[j], masks[i]&masks[j])
+			}
+		}
+	}
+
+	for i, m := range masks {
+		if m == 0 {
+			// The alpha mask is optional, except for BI_ALPHABITFIELDS.
+			if i < 3 || ctx.compressionCode == bI_ALPHABITFIELDS {
+				ctx.warn("bitfields", "%sMask is 0", names[i])
+			}
+			continue
+		}
+		if ctx.bitCount > 0 && ctx.bitCount < 32 && m>>uint(ctx.bitCount) != 0 {
+			ctx.warn("bitfields", "%sMask (0x%08x) uses bits beyond the %d-bit pixel",
+				names[i], m, ctx.bitCount)
+		}
+		shifted := m >> uint(bits.TrailingZeros32(m))
+		if shifted&(shifted+1) != 0 {
+			ctx.warn("bitfields", "%sMask (0x%08x) is not contiguous", names[i], m)
+		}
+	}
+}
+
+// Print the number of bits used by each of the masks in ctx.masks, and what
+// they add up to. offset is the end of the BITFIELDS segment.
+func printChannelDepths(ctx *ctx_type, offset int64) {
+	var channelNames = [4]string{"R", "G", "B", "A"}
+	var depths [4]int
+	var allBits uint32
+
+	for i, m := range ctx.masks {
+		depths[i] = bits.OnesCount32(m)
+		allBits |= m
+	}
+
+	startLine(ctx, offset)
+	ctx.printf("(Channel depths: R=%d G=%d B=%d A=%d)\n", depths[0], depths[1], depths[2],
+		depths[3])
+
+	startLine(ctx, offset)
+	ctx.print("(Color range: ")
+	for i, name := range channelNames {
+		if i > 0 {
+			ctx.print(", ")
+		}
+		if depths[i] == 0 {
+			ctx.printf("%s=n/a", name)
+		} else {
+			ctx.printf("%s=0-%d", name, uint64(1)<<uint(depths[i])-1)
+		}
+	}
+	ctx.print(")\n")
+
+	var terms []string
+	for i, name := range channelNames {
+		if depths[i] > 0 {
+			terms = append(terms, fmt.Sprintf("%s(%d)", name, depths[i]))
+		}
+	}
+	usedBits := bits.OnesCount32(allBits)
+	startLine(ctx, offset)
+	ctx.printf("(Total used bits: %s = %d of %d available)\n", strings.Join(terms, "+"),
+		usedBits, ctx.bitCount)
+	if ctx.bitCount > usedBits {
+		startLine(ctx, offset)
+		ctx.printf("(%d bits unused per pixel)\n", ctx.bitCount-usedBits)
+	}
+}
+
+func inspectColorTable(ctx *ctx_type, d []byte) error {
+	defer enterSection(ctx, "colortable")()
+	var i int
+	var r, g, b uint8
+	var x uint8
+
+	if skipSection(ctx, "colortable") {
+		return nil
+	}
+
+	printSectionBanner(ctx, "Color table", int64(len(d)))
+	startLine(ctx, 0)
+	ctx.printf("(Number of colors: %v)\n", ctx.palNumEntries)
+
+	if ctx.bmpVerID == "os2v2" {
+		bytesAvailableForPalette := int(ctx.bfOffBits) - int(ctx.pos)
+		if bytesAvailableForPalette == 3*ctx.palNumEntries {
+			// Some of the (very few) os2V2 sample files I've seen have this
+			// problem. It may not be widespread, so this hack may be fairly
+			// useless. But it shouldn't hurt anything.
+			ctx.warn("palette", "Bitmap overlaps color table. Assuming there are three bytes "+
+				"per color table entry, instead of four")
+			ctx.palBytesPerEntry = 3
+			ctx.palSizeInBytes = ctx.palNumEntries * ctx.palBytesPerEntry
+		}
+	}
+
+	// Print a header line
+	if ctx.palBytesPerEntry == 4 {
+		startLine(ctx, 0)
+		ctx.print("       R  G  B  x\n")
+		startLine(ctx, 0)
+		ctx.print("       -- -- -- --\n")
+	} else {
+		startLine(ctx, 0)
+		ctx.print("       R  G  B\n")
+		startLine(ctx, 0)
+		ctx.print("       -- -- --\n")
+	}
+
+	for i = 0; i < ctx.palNumEntries; i++ {
+		b = d[i*ctx.palBytesPerEntry]
+		g = d[i*ctx.palBytesPerEntry+1]
+		r = d[i*ctx.palBytesPerEntry+2]
+		if ctx.palBytesPerEntry == 4 {
+			x = d[i*ctx.palBytesPerEntry+3]
+		}
+
+		startLine(ctx, int64(i*ctx.palBytesPerEntry))
+		if ctx.bitCount <= 4 {
+			ctx.printf("   %x = ", i)
+		} else if ctx.bitCount <= 8 {
+			ctx.printf("  %02x = ", i)
+		} else {
+			// Not indexed color, so printing "=" would be misleading.
+			ctx.printf("[%3d]  ", i)
+		}
+		ctx.printf("%02x %02x %02x", r, g, b)
+		if ctx.palBytesPerEntry == 4 {
+			ctx.printf(" %02x", x)
+		}
+		ctx.print("\n")
+	}
+
+	if ctx.checkConventions {
+		checkPaletteConventions(ctx, d)
+	}
+	if ctx.palettePreview {
+		printPalettePreview(ctx)
+	}
+	if ctx.paletteSort != "index" {
+		printSortedPalette(ctx)
+	}
+	return nil
+}
+
+// Check for combinations of BitCount and compression that can't be decoded.
+// Like other problems with the pixels, these don't stop the rest of the file
+// from being inspected.
+func validateBppCompression(ctx *ctx_type) {
+	var msg string
+	switch {
+	case ctx.compressionType == "rle8" && ctx.bitCount != 8,
+		ctx.compressionType == "rle4" && ctx.bitCount != 4:
+		if ctx.bitCount == 16 || ctx.bitCount == 32 {
+			msg = fmt.Sprintf("%d-bpp cannot use RLE", ctx.bitCount)
+		} else {
+			msg = fmt.Sprintf("%d-bpp cannot use %s", ctx.bitCount,
+				strings.ToUpper(ctx.compressionType))
+		}
+	case ctx.compressionType == "none" && ctx.compressionCode == bI_BITFIELDS:
+		if ctx.bitCount == 24 {
+			ctx.warn("compression", "24-bpp with BITFIELDS is unusual (24-bpp pixels are always B-G-R)")
+		} else if ctx.bitCount <= 8 {
+			msg = fmt.Sprintf("%d-bpp cannot use BITFIELDS", ctx.bitCount)
+		}
+	}
+	if msg == "" {
+		return
+	}
+	ctx.printPixels = false
+	ctx.reportError("compression", "%s", msg)
+}
+
+func checkBitCount(ctx *ctx_type) error {
+	var ok bool
+	ok = false
+
+	switch ctx.bitCount {
+	case 0:
+		if (ctx.bmpVerID == "winv4" || ctx.bmpVerID == "winv5") &&
+			(ctx.compressionCode == bI_JPEG || ctx.compressionCode == bI_PNG) {
+			ok = true
+		}
+	case 1, 4, 8, 24:
+		ok = true
+	case 2:
+		if ctx.bmpVerID == "winv3" || ctx.bmpVerID == "wince" {
+			ok = true
+		}
+	case 16, 32:
+		if ctx.bmpVerID == "winv3" || ctx.bmpVerID == "partial" ||
+			ctx.bmpVerID == "52" || ctx.bmpVerID == "56" ||
+			ctx.bmpVerID == "winv4" || ctx.bmpVerID == "winv5" {
+			ok = true
+		}
+	}
+	if !ok {
+		ctx.score.deduct("bitCount")
+		return errors.New("Invalid BitCount")
+	}
+	return nil
+}
+
+func readInfoheader(ctx *ctx_type) error {
+	defer enterSection(ctx, "infoheader")()
+	var err error
+
+	if ctx.fileSize-ctx.pos < 4 {
+		return errors.New("Unexpected end of file")
+	}
+
+	printSectionBanner(ctx, "INFOHEADER", int64(ctx.infoHeaderSize))
+	if ctx.showRawInfoheader {
+		printRawInfoheader(ctx)
+	}
+	defer beginHeaderTable(ctx)()
+
+	// infoHeaderSize has already been read.
+	startLine(ctx, 0)
+	ctx.printf("Info header size: %v\n", ctx.infoHeaderSize)
+
+	var vi versionInfo_type
+	var knownVersion bool
+	vi, knownVersion = versionInfo[ctx.bmpVerID]
+
+	if ctx.fileSize-ctx.pos < int64(ctx.infoHeaderSize) {
+		return errors.New("Unexpected end of file")
+	}
+
+	if !knownVersion {
+		return errors.New("Unknown BMP version")
+	}
+	if vi.inspectInfoheaderFunc == nil {
+		return errors.New("Unsupported BMP version")
+	}
+
+	ctx.fieldNamePrefix = vi.prefix
+
+	d := ctx.file.bytes(ctx.pos, ctx.pos+int64(ctx.infoHeaderSize))
+	err = vi.inspectInfoheaderFunc(ctx, d)
+	if err != nil {
+		return err
+	}
+	ctx.infoHeader = bmpinspect.DecodeInfoHeader(d)
+
+	err = checkBitCount(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx.palSizeInBytes = ctx.palBytesPerEntry * ctx.palNumEntries
+
+	ctx.pos += int64(ctx.infoHeaderSize)
+
+	return nil
+}
+
+// Record information about a bad palette index.
+func badColor(ctx *ctx_type, n int, xpos int) {
+	if !ctx.badColorFlag {
+		ctx.badColorIndex = n
+		ctx.badColor_X = xpos
+		ctx.badColorFlag = true
+	}
+}
+
+func printRow_1(ctx *ctx_type, d []byte) {
+	var i int
+	var n byte
+	if !ctx.pixelOffsets {
+		ctx.print(" ")
+	}
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i/8), 7-i%8)
+		n = d[i/8]
+		n = n & (1 << (7 - uint(i)%8))
+		if n == 0 {
+			ctx.print("0")
+		} else {
+			n = 1
+			ctx.print("1")
+		}
+		if int(n) >= ctx.palNumEntries {
+			badColor(ctx, int(n), i)
+		}
+	}
+}
+
+func printRow_2(ctx *ctx_type, d []byte) {
+	var i int
+	var n byte
+
+	if !ctx.pixelOffsets {
+		ctx.print(" ")
+	}
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i/4), 2*(3-i%4))
+		n = (d[i/4] >> (2 * (3 - uint(i)%4))) & 0x03
+		ctx.printf("%x", n)
+		if int(n) >= ctx.palNumEntries {
+			badColor(ctx, int(n), i)
+		}
+	}
+}
+
+func printRow_4(ctx *ctx_type, d []byte) {
+	var i int
+	var n byte
+
+	if !ctx.pixelOffsets {
+		ctx.print(" ")
+	}
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i/2), 4-4*(i%2))
+		n = d[i/2]
+		if i%2 == 0 {
+			n = n >> 4
+		} else {
+			n = n & 0x0f
+		}
+		ctx.printf("%x", n)
+		if int(n) >= ctx.palNumEntries {
+			badColor(ctx, int(n), i)
+		}
+	}
+}
+
+func printRow_8(ctx *ctx_type, d []byte) {
+	var i int
+	var n byte
+
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i), -1)
+		n = d[i]
+		ctx.printf(" %02x", n)
+		if int(n) >= ctx.palNumEntries {
+			badColor(ctx, int(n), i)
+		}
+	}
+}
+
+// For -decode-pixels: Return the masks to decode 16- and 32-bit pixels with,
+// and whether there are any.
+func getDecodeMasks(ctx *ctx_type) ([4]uint32, bool) {
+	if !ctx.decodePixels {
+		return [4]uint32{}, false
+	}
+	masks := getEffectiveMasks(ctx)
+	return masks, masks[0] != 0 || masks[1] != 0 || masks[2] != 0 || masks[3] != 0
+}
+
+// Print the components of pixel value v, after the value itself. The alpha
+// component is only printed if there is an alpha mask.
+func printDecodedPixel(ctx *ctx_type, v uint32, masks [4]uint32) {
+	ctx.printf("(R=%02x,G=%02x,B=%02x", getMaskedChannel(v, masks[0]),
+		getMaskedChannel(v, masks[1]), getMaskedChannel(v, masks[2]))
+	if masks[3] != 0 {
+		ctx.printf(",A=%02x", getMaskedChannel(v, masks[3]))
+	}
+	ctx.print(")")
+}
+
+func printRow_16(ctx *ctx_type, d []byte) {
+	var i int
+	masks, decode := getDecodeMasks(ctx)
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*2), -1)
+		v := uint32(getWORD(d[i*2 : i*2+2]))
+		ctx.printf(" %04x", v)
+		if decode {
+			printDecodedPixel(ctx, v, masks)
+		}
+	}
+}
+
+func printRow_24(ctx *ctx_type, d []byte) {
+	var i int
+	var r, b, g byte
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*3), -1)
+		b = d[i*3]
+		g = d[i*3+1]
+		r = d[i*3+2]
+		ctx.printf(" %02x%02x%02x", r, g, b)
+	}
+}
+
+func printRow_32(ctx *ctx_type, d []byte) {
+	var i int
+	masks, decode := getDecodeMasks(ctx)
+	for i = 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*4), -1)
+		v := getDWORD(d[i*4 : i*4+4])
+		ctx.printf(" %08x", v)
+		if decode {
+			printDecodedPixel(ctx, v, masks)
+		}
+	}
+}
+
+type printRowFuncType func(ctx *ctx_type, d []byte)
+
+var printRowFuncs = map[int]printRowFuncType{
+	1:  printRow_1,
+	2:  printRow_2,
+	4:  printRow_4,
+	8:  printRow_8,
+	16: printRow_16,
+	24: printRow_24,
+	32: printRow_32,
+}
+
+// Like the printRow_N functions for indexed images, but follows each palette
+// index with the color it maps to. pal is the palette, from getPaletteRGB.
+func printRowWithRGB(ctx *ctx_type, d []byte, pal [][3]byte) {
+	pixelsPerByte := 8 / ctx.bitCount
+	for i := 0; i < ctx.imgWidth; i++ {
+		bit := -1
+		if pixelsPerByte > 1 {
+			bit = ctx.bitCount * (pixelsPerByte - 1 - i%pixelsPerByte)
+		}
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i/pixelsPerByte), bit)
+		n := int(getPixelValue(d, i, ctx.bitCount))
+		// For pixels smaller than a byte, printPixelOffset ends with a space.
+		if bit < 0 || !ctx.pixelOffsets {
+			ctx.print(" ")
+		}
+		if ctx.bitCount == 8 {
+			ctx.printf("%02x=", n)
+		} else {
+			ctx.printf("%x=", n)
+		}
+		if n >= len(pal) {
+			ctx.print("?")
+			badColor(ctx, n, i)
+			continue
+		}
+		ctx.printf("#%02x%02x%02x", pal[n][0], pal[n][1], pal[n][2])
+	}
+}
+
+// The widest image for which -show-palette-rgb is used, unless
+// -no-width-limit is set.
+const maxPaletteRGBWidth = 64
+
+func printUncompressedPixels(ctx *ctx_type) {
+	// Select a low-level "print row" function.
+	pR := printRowFuncs[ctx.bitCount]
+	if pR == nil {
+		return
+	}
+	if ctx.showPaletteRGB && ctx.bitCount <= 8 {
+		if ctx.imgWidth <= maxPaletteRGBWidth || ctx.noWidthLimit {
+			pal := getPaletteRGB(ctx)
+			pR = func(ctx *ctx_type, d []byte) {
+				printRowWithRGB(ctx, d, pal)
+			}
+		} else {
+			startLine(ctx, 0)
+			ctx.printf("(Palette colors not shown, because the image is wider than %v pixels)\n",
+				maxPaletteRGBWidth)
+		}
+	}
+	printPixelRows(ctx, pR, ctx.rowStride)
+}
+
+// Print each row of an uncompressed image, using the "print row" function
+// pR. The rows are read from the file one at a time; the caller must have
+// checked that they are all in the file.
+func printPixelRows(ctx *ctx_type, pR printRowFuncType, rowStride int64) {
+	var rowPhysical int64
+	var rowLogical int64
+	var offset int64
+
+	r := bufio.NewReader(ctx.file.section(ctx.pos, ctx.pos+rowStride*int64(ctx.imgHeight)))
+
+	for rowPhysical = 0; rowPhysical < int64(ctx.imgHeight); rowPhysical++ {
+		if ctx.topDown {
+			rowLogical = rowPhysical
+		} else {
+			rowLogical = int64(ctx.imgHeight) - 1 - rowPhysical
+		}
+
+		offset = rowPhysical * rowStride
+		row := make([]byte, rowStride)
+		io.ReadFull(r, row)
+		ctx.rowFileOffset = ctx.pos + offset
+		startLine(ctx, offset)
+		ctx.printf("row %d:", rowLogical)
+		if !ctx.rowChecksumsOnly {
+			pR(ctx, row)
+		}
+		if ctx.rowChecksums {
+			ctx.printf(" crc32=0x%08x", crc32.ChecksumIEEE(row))
+		}
+		ctx.print("\n")
+		if ctx.json != nil {
+			rowSize := (int64(ctx.imgWidth)*int64(ctx.bitCount) + 7) / 8
+			if rowSize > rowStride {
+				// The pixel format was overridden with a smaller one.
+				rowSize = rowStride
+			}
+			ctx.json.addRow(ctx, int(rowLogical), row[:rowSize])
+		}
+
+		// At the end of the row, display any pending warning.
+		if ctx.badColorFlag && !ctx.badColorWarned {
+			ctx.warn("palette", "Bad palette index 0x%02x at (%d,%d)", ctx.badColorIndex,
+				ctx.badColor_X, rowLogical)
+			ctx.badColorWarned = true
+		}
+	}
+}
+
+type rlectx_type struct {
+	bytesInThisRow   int
+	pixelsInThisRow  int // Number of pixels encoded in this row
+	rowEndedByDelta  bool
+	rowHeaderPrinted bool
+	xpos, ypos       int
+
+	badPosFlag   bool
+	badPosWarned bool
+	badPos_X     int
+	badPos_Y     int
+
+	// Run statistics. A "run" is a compressed or uncompressed run token.
+	rowNum        int // The row number printed at the start of this row
+	runsInThisRow int
+	maxRunsInRow  int
+	totalRuns     int
+	numRows       int
+
+	// Used to detect compressed runs that could have been merged with the
+	// previous one.
+	prevTokenWasRun bool
+	prevRunColor    uint32
+	prevRunLen      int
+	sameColorRuns   int // The current number of consecutive same-color runs
+	maxSameColor    int // The maximum sameColorRuns in this row
+
+	verboseRowWarned   bool
+	unmergedRunsWarned bool
+
+	// For -decode-rle=replace: Whether the last thing printed was a pixel.
+	inPixelGroup bool
+	// For -decode-rle=inline: The pixels of the current uncompressed run.
+	uncRunPixelStrs []string
+
+	// For -rle-analysis.
+	runTokenSize     int // The size of a compressed run token: 2, or 4 for RLE24
+	compRunBytesUsed int
+	uncRunBytesUsed  int // Including the 2-byte headers
+	numUncRuns       int
+	uncRunPixels     int
+	mergeableBytes   int // Bytes that would be saved by merging runs
+	runLengthHist    [len(rleRunLengthBuckets)]int
+
+	// For -row-checksums and -format=json: The decoded pixels of this row,
+	// one byte per pixel (three for RLE24).
+	rowDecodeBuffer []byte
+}
+
+// The number of run tokens in a row above which we warn.
+const maxReasonableRunsInRow = 256
+
+// Record a compressed run of n pixels of the given color. mergeable says
+// whether it could be merged with a previous run of the same color.
+func noteCompressedRun(rlectx *rlectx_type, n int, color uint32, mergeable bool) {
+	if rlectx.prevTokenWasRun && mergeable && color == rlectx.prevRunColor &&
+		rlectx.prevRunLen+n <= 255 {
+		rlectx.sameColorRuns++
+		rlectx.mergeableBytes += rlectx.runTokenSize
+	} else {
+		rlectx.sameColorRuns = 1
+	}
+	if rlectx.sameColorRuns > rlectx.maxSameColor {
+		rlectx.maxSameColor = rlectx.sameColorRuns
+	}
+	rlectx.prevTokenWasRun = true
+	rlectx.prevRunColor = color
+	rlectx.prevRunLen = n
+	rlectx.runsInThisRow++
+	rlectx.totalRuns++
+	rlectx.compRunBytesUsed += rlectx.runTokenSize
+	rlectx.runLengthHist[rleRunLengthBucket(n)]++
+}
+
+// Record an uncompressed run.
+func noteUncompressedRun(rlectx *rlectx_type, n int) {
+	rlectx.prevTokenWasRun = false
+	rlectx.runsInThisRow++
+	rlectx.totalRuns++
+	rlectx.numUncRuns++
+	rlectx.uncRunPixels += n
+	rlectx.uncRunBytesUsed += 2
+}
+
+// Update the run statistics at the end of a row, and warn about anything
+// unusual.
+func endRLERowRuns(ctx *ctx_type, rlectx *rlectx_type) {
+	defer func() {
+		rlectx.runsInThisRow = 0
+		rlectx.prevTokenWasRun = false
+		rlectx.sameColorRuns = 0
+		rlectx.maxSameColor = 0
+	}()
+
+	// Don't count the pseudo-row that contains only the EOBMP marker, or
+	// anything else outside the image.
+	if rlectx.rowNum < 0 {
+		return
+	}
+
+	rlectx.numRows++
+	if rlectx.runsInThisRow > rlectx.maxRunsInRow {
+		rlectx.maxRunsInRow = rlectx.runsInThisRow
+	}
+
+	if rlectx.runsInThisRow > maxReasonableRunsInRow && !rlectx.verboseRowWarned {
+		ctx.warn("pixels", "Row %d has %d run tokens (encoder may be overly verbose)",
+			rlectx.rowNum, rlectx.runsInThisRow)
+		rlectx.verboseRowWarned = true
+	}
+	if rlectx.maxSameColor > 1 && !rlectx.unmergedRunsWarned {
+		ctx.warn("pixels", "Row %d has %d consecutive same-color compressed runs "+
+			"(encoder not merging adjacent runs)", rlectx.rowNum, rlectx.maxSameColor)
+		rlectx.unmergedRunsWarned = true
+	}
+}
+
+func printRLERunStatistics(ctx *ctx_type, rlectx *rlectx_type, pos int64) {
+	if rlectx.numRows < 1 {
+		return
+	}
+	startLine(ctx, pos)
+	ctx.printf("(Max runs in a single row: %d)\n", rlectx.maxRunsInRow)
+	startLine(ctx, pos)
+	ctx.printf("(Total run tokens: %d)\n", rlectx.totalRuns)
+	startLine(ctx, pos)
+	ctx.printf("(Average runs per row: %.1f)\n", float64(rlectx.totalRuns)/float64(rlectx.numRows))
+}
+
+// Do some things that need to be done at the end of every row.
+func endRLERow(ctx *ctx_type, rlectx *rlectx_type) {
+	if rlectx.rowHeaderPrinted {
+		// The pseudo-row that contains only the EOBMP marker is not part of
+		// the image, so its pixel count means nothing.
+		if ctx.annotateRLE && rlectx.rowNum >= 0 {
+			ctx.printf(" (%v pixels", rlectx.pixelsInThisRow)
+			if rlectx.rowEndedByDelta {
+				ctx.print(" before DELTA")
+			} else if rlectx.pixelsInThisRow != ctx.imgWidth {
+				ctx.printf(", expected %v", ctx.imgWidth)
+			}
+			ctx.print(")")
+		}
+		ctx.printf(" [%v bytes]", rlectx.bytesInThisRow)
+		if ctx.rowChecksums && rlectx.rowNum >= 0 {
+			ctx.printf(" crc32=0x%08x", crc32.ChecksumIEEE(rlectx.rowDecodeBuffer))
+		}
+		ctx.print("\n")
+		if ctx.json != nil && rlectx.rowNum >= 0 {
+			ctx.json.addRow(ctx, rlectx.rowNum, rlectx.rowDecodeBuffer)
+		}
+		endRLERowRuns(ctx, rlectx)
+		rlectx.bytesInThisRow = 0
+		rlectx.rowDecodeBuffer = rlectx.rowDecodeBuffer[:0]
+		rlectx.pixelsInThisRow = 0
+		rlectx.rowEndedByDelta = false
+		rlectx.rowHeaderPrinted = false
+	}
+
+	// Print pending warnings.
+
+	if rlectx.badPosFlag && !rlectx.badPosWarned {
+		ctx.warn("pixels", "Out of bounds pixel (%d,%d)", rlectx.badPos_X, rlectx.badPos_Y)
+		rlectx.badPosWarned = true
+	}
+
+	if ctx.badColorFlag && !ctx.badColorWarned {
+		ctx.warn("palette", "Bad palette index 0x%02x at (%d,%d)", ctx.badColorIndex,
+			ctx.badColor_X, ctx.badColor_Y)
+		ctx.badColorWarned = true
+	}
+}
+
+func checkRLEPosAndColor(ctx *ctx_type, rlectx *rlectx_type, n byte) {
+	if (rlectx.xpos >= ctx.imgWidth || rlectx.ypos < 0) && !rlectx.badPosFlag {
+		rlectx.badPosFlag = true
+		rlectx.badPos_X = rlectx.xpos
+		rlectx.badPos_Y = rlectx.ypos
+	}
+
+	if ctx.compressionCode == bI_RLE24 {
+		return
+	}
+
+	if int(n) >= ctx.palNumEntries && !ctx.badColorFlag {
+		ctx.badColorIndex = int(n)
+		ctx.badColor_X = rlectx.xpos
+		ctx.badColor_Y = rlectx.ypos
+		ctx.badColorFlag = true
+	}
+}
+
+// With -decode-rle=replace, print one decoded pixel, formatted the way
+// printUncompressedPixels would: 4-bit pixels are run together, and others
+// are separated by spaces.
+func printDecodedRLEPixel(ctx *ctx_type, rlectx *rlectx_type, s string) {
+	if ctx.bitCount != 4 || !rlectx.inPixelGroup {
+		ctx.pixPrint(" ")
+	}
+	ctx.pixPrint(s)
+	rlectx.inPixelGroup = true
+}
+
+// With -decode-rle=replace, print all the pixels of a compressed run.
+func printDecodedRLERun(ctx *ctx_type, rlectx *rlectx_type, n int, s1, s2 string) {
+	for i := 0; i < n; i++ {
+		if i%2 == 0 {
+			printDecodedRLEPixel(ctx, rlectx, s1)
+		} else {
+			printDecodedRLEPixel(ctx, rlectx, s2)
+		}
+	}
+}
+
+// Print something in the RLE data that is not a pixel.
+func printRLEControl(ctx *ctx_type, rlectx *rlectx_type, s string) {
+	ctx.print(s)
+	rlectx.inPixelGroup = false
+}
+
+// Record decoded pixels for -row-checksums and -format=json. pix is the
+// pattern of pixels to record, which is repeated until n pixels have been
+// recorded.
+func noteDecodedPixels(ctx *ctx_type, rlectx *rlectx_type, n int, pix ...[]byte) {
+	if !ctx.rowChecksums && ctx.json == nil {
+		return
+	}
+	for i := 0; i < n; i++ {
+		rlectx.rowDecodeBuffer = append(rlectx.rowDecodeBuffer, pix[i%len(pix)]...)
+	}
+}
+
+// Print one pixel of an uncompressed run.
+func printRLE4Pixel(ctx *ctx_type, rlectx *rlectx_type, n byte) {
+	if ctx.decodeRLE == decodeRLEReplace {
+		printDecodedRLEPixel(ctx, rlectx, fmt.Sprintf("%x", n))
+	} else {
+		ctx.pixPrintf("%x", n)
+		noteInlineRLEPixel(ctx, rlectx, fmt.Sprintf("%x", n))
+	}
+	noteDecodedPixels(ctx, rlectx, 1, []byte{n})
+	checkRLEPosAndColor(ctx, rlectx, n)
+}
+
+func printRLE8Pixel(ctx *ctx_type, rlectx *rlectx_type, n byte) {
+	if ctx.decodeRLE == decodeRLEReplace {
+		printDecodedRLEPixel(ctx, rlectx, fmt.Sprintf("%02x", n))
+	} else {
+		ctx.pixPrintf("%02x", n)
+		noteInlineRLEPixel(ctx, rlectx, fmt.Sprintf("%02x", n))
+	}
+	noteDecodedPixels(ctx, rlectx, 1, []byte{n})
+	checkRLEPosAndColor(ctx, rlectx, n)
+}
+
+func formatRLE24Pixel(clr []byte) string {
+	return fmt.Sprintf("%02x%02x%02x", clr[2], clr[1], clr[0])
+}
+
+func printRLE24Pixel(ctx *ctx_type, rlectx *rlectx_type, clr []byte) {
+	if ctx.decodeRLE == decodeRLEReplace {
+		printDecodedRLEPixel(ctx, rlectx, formatRLE24Pixel(clr))
+	} else {
+		ctx.pixPrint(formatRLE24Pixel(clr))
+	}
+	checkRLEPosAndColor(ctx, rlectx, 0)
+}
+
+// The column at which -decode-rle=inline breaks lines, and the indent of the
+// continuation lines.
+const (
+	maxRLELineLen   = 120
+	rleContinuation = "           "
+)
+
+// For -decode-rle=inline: Record a pixel of an uncompressed run, to be
+// printed at the end of the run. Pixels of compressed runs are not recorded
+// here.
+func noteInlineRLEPixel(ctx *ctx_type, rlectx *rlectx_type, s string) {
+	if ctx.decodeRLE == decodeRLEInline {
+		rlectx.uncRunPixelStrs = append(rlectx.uncRunPixelStrs, s)
+	}
+}
+
+// For -decode-rle=inline: Print the pixels of a run, in brackets. If a line
+// would become longer than maxRLELineLen, it is continued on a new line.
+func printInlineRLEPixels(ctx *ctx_type, pixels []string) {
+	if ctx.decodeRLE != decodeRLEInline || ctx.rowChecksumsOnly ||
+		len(pixels) == 0 {
+		return
+	}
+	for i, s := range pixels {
+		if i == 0 {
+			s = "[" + s
+		}
+		if i == len(pixels)-1 {
+			s += "]"
+		}
+		sep := " "
+		if ctx.column+len(sep)+len(s) > maxRLELineLen {
+			ctx.print("\n" + rleContinuation)
+			sep = ""
+		}
+		ctx.print(sep + s)
+	}
+}
+
+// For -decode-rle=inline: Print a compressed run of n pixels, alternating
+// between s1 and s2.
+func printInlineRLERun(ctx *ctx_type, n int, s1, s2 string) {
+	if ctx.decodeRLE != decodeRLEInline {
+		return
+	}
+	pixels := make([]string, n)
+	for i := range pixels {
+		if i%2 == 0 {
+			pixels[i] = s1
+		} else {
+			pixels[i] = s2
+		}
+	}
+	printInlineRLEPixels(ctx, pixels)
+}
+
+// Called at the end of the RLE data, to record the number of rows it
+// encodes. Rows skipped by a DELTA code are counted.
+func countRLERows(ctx *ctx_type, rlectx *rlectx_type) {
+	ctx.rleRowsDecoded = ctx.imgHeight - 1 - rlectx.ypos
+	if rlectx.xpos > 0 {
+		// Count the partial row.
+		ctx.rleRowsDecoded++
+	}
+}
+
+// Called when the RLE data ends without an EOBMP code. Some encoders omit
+// it, so the note can be turned off, but a wrong number of rows is still
+// reported.
+func reportMissingEOBMP(ctx *ctx_type, pos int64) {
+	if !ctx.missingEOBMPOK {
+		startLine(ctx, pos)
+		ctx.printf("(RLE stream ended at file offset %v without EOBMP marker)\n", ctx.pos+pos)
+	}
+
+	rowsDecoded := ctx.rleRowsDecoded
+	if rowsDecoded == ctx.imgHeight {
+		startLine(ctx, pos)
+		ctx.printf("(Rows decoded: %v, as expected)\n", rowsDecoded)
+	} else {
+		ctx.warn("pixels", "Rows decoded: %v, expected %v", rowsDecoded, ctx.imgHeight)
+	}
+}
+
+func printRLECompressedPixels(ctx *ctx_type, r io.Reader) {
+	if ctx.bitCount != 4 && ctx.bitCount != 8 && ctx.bitCount != 24 {
+		return
+	}
+	if ctx.bitCount == 4 && ctx.compressionCode != bI_RLE4 {
+		return
+	}
+	if ctx.bitCount == 8 && ctx.compressionCode != bI_RLE8 {
+		return
+	}
+	if ctx.bitCount == 24 && ctx.compressionCode != bI_RLE24 {
+		return
+	}
+
+	rlectx := new(rlectx_type)
+	// The compressed data is read sequentially, so it doesn't all have to be
+	// in memory.
+	br := bufio.NewReader(r)
+	var pos int = 0 // current position in the compressed data
+	var unc_pixels_left int = 0
+	var b1, b2 byte
+	var deltaFlag bool
+	var rle24pendingFlag bool // Is an RLE24 compression code pending?
+	var clr24bytes [4]byte    // Pending bytes, used with RLE24
+	var clr24bytes_used int = 0
+
+	rlectx.runTokenSize = 2
+	if ctx.compressionCode == bI_RLE24 {
+		rlectx.runTokenSize = 4
+	}
+	rlectx.xpos = 0
+	// RLE-compressed BMPs are not allowed to be top-down.
+	rlectx.ypos = ctx.imgHeight - 1
+
+	for {
+		token, _ := br.Peek(2)
+		if len(token) < 2 {
+			// Compressed data ended without an EOBMP code.
+			countRLERows(ctx, rlectx)
+			endRLERow(ctx, rlectx)
+			reportMissingEOBMP(ctx, int64(pos))
+			break
+		}
+
+		if !rlectx.rowHeaderPrinted {
+			startLine(ctx, int64(pos))
+			rlectx.rowNum = rlectx.ypos
+			if rlectx.ypos >= 0 {
+				ctx.printf("row %d:", rlectx.ypos)
+			} else {
+				ctx.print("row n/a:")
+			}
+			rlectx.rowHeaderPrinted = true
+			rlectx.inPixelGroup = false
+		}
+
+		// Read bytes 2 at a time.
+		// This strategy works pretty well for RLE4 and RLE8, but not as well
+		// for RLE24.
+		b1 = token[0]
+		b2 = token[1]
+		br.Discard(2)
+		pos += 2
+		rlectx.bytesInThisRow += 2
+
+		if unc_pixels_left > 0 {
+			rlectx.uncRunBytesUsed += 2
+			if ctx.compressionCode == bI_RLE24 {
+				// Append these 2 bytes to our color buffer
+				clr24bytes[clr24bytes_used] = b1
+				clr24bytes_used++
+				clr24bytes[clr24bytes_used] = b2
+				clr24bytes_used++
+
+				if clr24bytes_used >= 3 {
+					// We've accumulated enough bytes for a pixel
+					printRLE24Pixel(ctx, rlectx, clr24bytes[0:3])
+					noteInlineRLEPixel(ctx, rlectx, formatRLE24Pixel(clr24bytes[0:3]))
+					noteDecodedPixels(ctx, rlectx, 1, clr24bytes[0:3])
+					rlectx.xpos++
+					unc_pixels_left--
+					if unc_pixels_left > 0 && ctx.decodeRLE != decodeRLEReplace {
+						ctx.pixPrintf(" ")
+					}
+					// If there was a leftover byte, move it to the beginning
+					if clr24bytes_used == 4 {
+						clr24bytes[0] = clr24bytes[3]
+					}
+					clr24bytes_used -= 3
+				}
+
+				if unc_pixels_left < 1 {
+					clr24bytes_used = 0 // Discard any padding byte
+				}
+			} else if ctx.compressionCode == bI_RLE4 {
+				// The two bytes we read store up to 4 uncompressed pixels.
+				printRLE4Pixel(ctx, rlectx, b1>>4)
+				rlectx.xpos++
+				unc_pixels_left--
+				if unc_pixels_left > 0 {
+					printRLE4Pixel(ctx, rlectx, b1&0x0f)
+					rlectx.xpos++
+					unc_pixels_left--
+				}
+				if unc_pixels_left > 0 {
+					printRLE4Pixel(ctx, rlectx, b2>>4)
+					rlectx.xpos++
+					unc_pixels_left--
+				}
+				if unc_pixels_left > 0 {
+					printRLE4Pixel(ctx, rlectx, b2&0x0f)
+					rlectx.xpos++
+					unc_pixels_left--
+				}
+			} else { // RLE8
+				printRLE8Pixel(ctx, rlectx, b1)
+				rlectx.xpos++
+				unc_pixels_left--
+				if unc_pixels_left > 0 {
+					if ctx.decodeRLE != decodeRLEReplace {
+						ctx.pixPrint(" ")
+					}
+					printRLE8Pixel(ctx, rlectx, b2)
+					rlectx.xpos++
+					unc_pixels_left--
+				}
+				if unc_pixels_left > 0 && ctx.decodeRLE != decodeRLEReplace {
+					ctx.pixPrint(" ")
+				}
+			}
+			if unc_pixels_left == 0 && ctx.decodeRLE != decodeRLEReplace {
+				ctx.pixPrint("}")
+				printInlineRLEPixels(ctx, rlectx.uncRunPixelStrs)
+				rlectx.uncRunPixelStrs = rlectx.uncRunPixelStrs[:0]
+			}
+		} else if deltaFlag {
+			printRLEControl(ctx, rlectx, fmt.Sprintf("(%v,%v)", b1, b2))
+			rlectx.xpos += int(b1)
+			rlectx.ypos -= int(b2)
+			if b2 > 0 {
+				// A nonzero y delta moves us to a different row, so end the
+				// current row.
+				rlectx.rowEndedByDelta = true
+				endRLERow(ctx, rlectx)
+			}
+			deltaFlag = false
+		} else if rle24pendingFlag { // the last 2 bytes of a 4-byte RLE code
+			clr24bytes[2] = b1
+			clr24bytes[3] = b2
+			noteCompressedRun(rlectx, int(clr24bytes[0]), uint32(clr24bytes[1])|
+				uint32(b1)<<8|uint32(b2)<<16, true)
+			noteDecodedPixels(ctx, rlectx, int(clr24bytes[0]), clr24bytes[1:4])
+			if ctx.decodeRLE == decodeRLEReplace {
+				s := formatRLE24Pixel(clr24bytes[1:4])
+				printDecodedRLERun(ctx, rlectx, int(clr24bytes[0]), s, s)
+			} else {
+				printRLE24Pixel(ctx, rlectx, clr24bytes[1:4])
+				ctx.pixPrint("}")
+				s := formatRLE24Pixel(clr24bytes[1:4])
+				printInlineRLERun(ctx, int(clr24bytes[0]), s, s)
+			}
+			rlectx.xpos += int(clr24bytes[0]) - 1
+			checkRLEPosAndColor(ctx, rlectx, 0)
+			rlectx.xpos++
+			rle24pendingFlag = false
+			clr24bytes_used = 0
+		} else if b1 == 0 {
+			if b2 == 0 {
+				printRLEControl(ctx, rlectx, " EOL")
+				endRLERow(ctx, rlectx)
+				rlectx.ypos--
+				rlectx.xpos = 0
+			} else if b2 == 1 {
+				printRLEControl(ctx, rlectx, " EOBMP")
+				countRLERows(ctx, rlectx)
+				endRLERow(ctx, rlectx)
+				break
+			} else if b2 == 2 {
+				printRLEControl(ctx, rlectx, " DELTA")
+				rlectx.prevTokenWasRun = false
+				deltaFlag = true
+			} else {
+				// An upcoming uncompressed run of b2 pixels
+				noteUncompressedRun(rlectx, int(b2))
+				ctx.printPixelOffset(ctx.pos+int64(pos-2), -1)
+				if ctx.decodeRLE != decodeRLEReplace {
+					ctx.pixPrintf(" u%v{", b2)
+				}
+				unc_pixels_left = int(b2)
+				rlectx.pixelsInThisRow += int(b2)
+			}
+		} else { // Compressed pixels
+			rlectx.pixelsInThisRow += int(b1)
+			ctx.printPixelOffset(ctx.pos+int64(pos-2), -1)
+			if ctx.compressionCode == bI_RLE24 {
+				if ctx.decodeRLE != decodeRLEReplace {
+					ctx.pixPrintf(" %v{", b1)
+				}
+				checkRLEPosAndColor(ctx, rlectx, 0)
+				clr24bytes[0] = b1
+				clr24bytes[1] = b2
+				clr24bytes_used = 2
+				rle24pendingFlag = true
+			} else if ctx.compressionCode == bI_RLE4 {
+				var n1 byte = (b2 & 0xf0) >> 4
+				var n2 byte = b2 & 0x0f
+				// Runs with a two-color pattern can only be merged if the
+				// previous run had an even length.
+				noteCompressedRun(rlectx, int(b1), uint32(b2), rlectx.prevRunLen%2 == 0)
+				noteDecodedPixels(ctx, rlectx, int(b1), []byte{n1}, []byte{n2})
+				if ctx.decodeRLE == decodeRLEReplace {
+					printDecodedRLERun(ctx, rlectx, int(b1), fmt.Sprintf("%x", n1),
+						fmt.Sprintf("%x", n2))
+				} else if b1 == 1 {
+					ctx.pixPrintf(" %v{%x}", b1, n1)
+				} else if n1 == n2 {
+					ctx.pixPrintf(" %v{%x}", b1, n1)
+				} else {
+					ctx.pixPrintf(" %v{%x%x}", b1, n1, n2)
+				}
+				if ctx.decodeRLE != decodeRLEReplace {
+					printInlineRLERun(ctx, int(b1), fmt.Sprintf("%x", n1),
+						fmt.Sprintf("%x", n2))
+				}
+
+				// Check the first pixel of this run for valid color and position.
+				checkRLEPosAndColor(ctx, rlectx, n1)
+				rlectx.xpos++
+				if b1 > 1 {
+					// Check the second pixel.
+					checkRLEPosAndColor(ctx, rlectx, n2)
+					rlectx.xpos++
+
+					if b1 > 2 {
+						// Check the last pixel's position.
+						rlectx.xpos += int(b1) - 3
+						checkRLEPosAndColor(ctx, rlectx, 0)
+						rlectx.xpos++
+					}
+				}
+
+			} else { // RLE8
+				if ctx.decodeRLE == decodeRLEReplace {
+					s := fmt.Sprintf("%02x", b2)
+					printDecodedRLERun(ctx, rlectx, int(b1), s, s)
+				} else {
+					ctx.pixPrintf(" %v{%02x}", b1, b2)
+					s := fmt.Sprintf("%02x", b2)
+					printInlineRLERun(ctx, int(b1), s, s)
+				}
+				noteCompressedRun(rlectx, int(b1), uint32(b2), true)
+				noteDecodedPixels(ctx, rlectx, int(b1), []byte{b2})
+
+				// Check the first and last pixel of this run.
+				checkRLEPosAndColor(ctx, rlectx, b2)
+				rlectx.xpos += int(b1) - 1
+				checkRLEPosAndColor(ctx, rlectx, b2)
+				rlectx.xpos++
+			}
+		}
+	}
+
+	ctx.actualBitsSize = int64(pos)
+	if ctx.json != nil {
+		ctx.json.setRLEStats(rlectx)
+	}
+	printRLERunStatistics(ctx, rlectx, int64(pos))
+	printCompressionRatio(ctx, int64(pos))
+	if ctx.rleAnalysis {
+		printRLEAnalysis(ctx, rlectx, int64(pos))
+	}
+}
+
+// Format a number of bytes using the largest suitable unit (1 KB = 1024
+// bytes).
+func humanBytes(n int64) string {
+	units := []string{"KB", "MB", "GB"}
+	if n < 1024 {
+		return fmt.Sprintf("%v bytes", n)
+	}
+	x := float64(n) / 1024
+	u := 0
+	for x >= 1024 && u < len(units)-1 {
+		x /= 1024
+		u++
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", x), ".0") + " " + units[u]
+}
+
+func printSizeInBytes(ctx *ctx_type, n int64) {
+	if n >= 1024 {
+		ctx.printf("%v bytes = %s", n, humanBytes(n))
+	} else {
+		ctx.print(humanBytes(n))
+	}
+}
+
+// Describe a compression ratio, where 1.0 means no compression.
+func compressionRatingName(ratio float64) string {
+	switch {
+	case ratio < 0.3:
+		return "Excellent (< 30%)"
+	case ratio < 0.6:
+		return "Good (30-60%)"
+	case ratio < 0.8:
+		return "Moderate (60-80%)"
+	case ratio <= 1.0:
+		return "Poor (80-100%)"
+	}
+	return "Negative compression (> 100%)"
+}
+
+// The smallest possible size of the compressed data, which is achieved when
+// every pixel is the same color. Each row needs one run per 255 pixels,
+// plus an EOL (or, for the last row, EOBMP) code.
+func bestRLECompressedSize(ctx *ctx_type) int64 {
+	runsPerRow := (int64(ctx.imgWidth) + 254) / 255
+	bytesPerRun := int64(2)
+	if ctx.compressionCode == bI_RLE24 {
+		bytesPerRun = 4
+	}
+	return int64(ctx.imgHeight) * (runsPerRow*bytesPerRun + 2)
+}
+
+// pos is the offset of the end of the compressed data.
+func printCompressionRatio(ctx *ctx_type, pos int64) {
+	if ctx.calculatedSize < 1 || ctx.actualBitsSize < 1 {
+		return
+	}
+
+	var ratio float64
+	ratio = float64(ctx.actualBitsSize) / float64(ctx.calculatedSize)
+	startLine(ctx, pos)
+	ctx.printf("(Compression ratio: %v/%v = %.2f%%)\n", ctx.actualBitsSize,
+		ctx.calculatedSize, ratio*100.0)
+
+	startLine(ctx, pos)
+	ctx.print("(Uncompressed size: ")
+	printSizeInBytes(ctx, ctx.calculatedSize)
+	ctx.print(")\n")
+	startLine(ctx, pos)
+	ctx.print("(Compressed size: ")
+	printSizeInBytes(ctx, ctx.actualBitsSize)
+	ctx.print(")\n")
+
+	startLine(ctx, pos)
+	if ratio == 1.0 {
+		ctx.printf("(Same size as uncompressed: %s)\n", compressionRatingName(ratio))
+	} else if ratio < 1.0 {
+		ctx.printf("(%.1f\u00d7 smaller than uncompressed: %s)\n", 1.0/ratio,
+			compressionRatingName(ratio))
+	} else {
+		ctx.printf("(%.1f\u00d7 larger than uncompressed: %s)\n", ratio,
+			compressionRatingName(ratio))
+	}
+
+	bestSize := bestRLECompressedSize(ctx)
+	startLine(ctx, pos)
+	ctx.printf("(Best possible ratio, for a one-color image: %v/%v = %.2f%%)\n",
+		bestSize, ctx.calculatedSize, 100.0*float64(bestSize)/float64(ctx.calculatedSize))
+}
+
+func printRowMapEntry(ctx *ctx_type, rowPhysical int64) {
+	var rowLogical int64
+	if ctx.topDown {
+		rowLogical = rowPhysical
+	} else {
+		rowLogical = int64(ctx.imgHeight) - 1 - rowPhysical
+	}
+	offset := ctx.pos + rowPhysical*ctx.rowStride
+	startLineAbsolute(ctx, offset)
+	ctx.printf("Physical row %d → Logical row %d → File offset 0x%x\n",
+		rowPhysical, rowLogical, offset)
+}
+
+// Print a table showing where each row is stored in the file.
+func printRowMap(ctx *ctx_type) {
+	var rowPhysical int64
+	var numRows int64 = int64(ctx.imgHeight)
+
+	startLine(ctx, 0)
+	ctx.print("----- Row map -----\n")
+
+	switch ctx.compressionType {
+	case "none":
+	case "rle4", "rle8", "rle24":
+		startLine(ctx, 0)
+		ctx.print("(RLE: mapping depends on data stream)\n")
+		return
+	default:
+		return
+	}
+
+	if numRows <= 20 {
+		for rowPhysical = 0; rowPhysical < numRows; rowPhysical++ {
+			printRowMapEntry(ctx, rowPhysical)
+		}
+		return
+	}
+
+	for rowPhysical = 0; rowPhysical < 5; rowPhysical++ {
+		printRowMapEntry(ctx, rowPhysical)
+	}
+	startLine(ctx, 5*ctx.rowStride)
+	ctx.printf("... (%d more rows) ...\n", numRows-10)
+	for rowPhysical = numRows - 5; rowPhysical < numRows; rowPhysical++ {
+		printRowMapEntry(ctx, rowPhysical)
+	}
+}
+
+// For 16- and 32-bit BI_RGB images, count the pixels that have any of the
+// unused bits set (bit 15, or bits 31-24).
+func countUnusedBitsSet(ctx *ctx_type, d []byte) int {
+	var count int
+	var offset int64
+
+	for row := 0; row < ctx.imgHeight; row++ {
+		offset = int64(row) * ctx.rowStride
+		for i := 0; i < ctx.imgWidth; i++ {
+			if ctx.bitCount == 16 {
+				if getWORD(d[offset+int64(i)*2:])&0x8000 != 0 {
+					count++
+				}
+			} else {
+				if getDWORD(d[offset+int64(i)*4:])&0xff000000 != 0 {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func reportUnusedBits(ctx *ctx_type, d []byte) {
+	numPixels := ctx.imgWidth * ctx.imgHeight
+	count := countUnusedBitsSet(ctx, d)
+
+	startLine(ctx, 0)
+	if ctx.bitCount == 16 {
+		ctx.printf("(Unused bit 15: %v of %v pixels have bit 15 set)\n", count, numPixels)
+		if count == numPixels {
+			startLine(ctx, 0)
+			ctx.print("(Possible 1-5-5-5 image misidentified as BI_RGB? Consider using BITFIELDS)\n")
+		}
+	} else {
+		ctx.printf("(Unused bits 31-24: %v of %v pixels have unused bits set)\n", count, numPixels)
+	}
+}
+
+// Print how much of each row of an uncompressed image is padding.
+func printRowPadding(ctx *ctx_type) {
+	dataBytes := (int64(ctx.imgWidth)*int64(ctx.bitCount) + 7) / 8
+	paddingBytes := ctx.rowStride - dataBytes
+
+	startLine(ctx, 0)
+	if paddingBytes == 0 {
+		ctx.print("(No row padding needed)\n")
+		return
+	}
+	ctx.printf("(Row stride: %v bytes = %v data bytes + %v padding bytes per row)\n",
+		ctx.rowStride, dataBytes, paddingBytes)
+
+	totalPadding := paddingBytes * int64(ctx.imgHeight)
+	overhead := 100.0 * float64(totalPadding) / float64(ctx.calculatedSize)
+	startLine(ctx, 0)
+	ctx.printf("(Total padding bytes: %v)\n", totalPadding)
+	startLine(ctx, 0)
+	ctx.printf("(Padding overhead: %.1f%%)\n", overhead)
+	if overhead > 20 {
+		startLine(ctx, 0)
+		ctx.print("(Padding is a large part of the image size, due to the narrow width)\n")
+	}
+}
+
+// Reports whether ctx.calculatedSize is a real size. It is not if the height
+// is negative (biHeight = -2147483648 can't be negated), or if the
+// multiplication overflowed.
+func calculatedSizeValid(ctx *ctx_type) bool {
+	if ctx.calculatedSize < 0 {
+		return false
+	}
+	return ctx.imgHeight == 0 || ctx.calculatedSize/int64(ctx.imgHeight) == ctx.rowStride
+}
+
+func inspectBits(ctx *ctx_type) error {
+	defer enterSection(ctx, "bits")()
+	// The bits are read as they are needed. Only the options that analyze
+	// the whole image read all of them into memory, with allBits.
+	var d []byte
+	allBits := func() []byte {
+		if d == nil {
+			d = ctx.file.read(ctx.pos, ctx.fileSize)
+		}
+		return d
+	}
+
+	// bitsLen extends to the end of the file, but an embedded profile may
+	// follow the bits.
+	bitsLen := ctx.fileSize - ctx.pos
+	bitsSectionSize := bitsLen
+	if ctx.hasProfile && !ctx.profileIsLinked && ctx.profileOffset > ctx.pos &&
+		ctx.profileOffset-ctx.pos < bitsSectionSize {
+		bitsSectionSize = ctx.profileOffset - ctx.pos
+	}
+	printSectionBanner(ctx, "Bitmap bits", bitsSectionSize)
+	ctx.bits = &BitmapBits{Offset: ctx.pos, Size: bitsSectionSize}
+	startLine(ctx, 0)
+	ctx.print("(Size given by SizeImage field:     ")
+	if ctx.sizeImage == 0 {
+		ctx.print("n/a)\n")
+	} else {
+		ctx.printf("%v)\n", ctx.sizeImage)
+	}
+
+	ctx.rowStride = (((int64(ctx.imgWidth) * int64(ctx.bitCount)) + 31) / 32) * 4
+	ctx.calculatedSize = ctx.rowStride * int64(ctx.imgHeight)
+	startLine(ctx, 0)
+	ctx.print("(Size calculated from width/height: ")
+	if ctx.isCompressed {
+		// Can't predict the size of compressed images.
+		ctx.print("n/a)\n")
+	} else {
+		// An uncompressed image
+		ctx.actualBitsSize = ctx.calculatedSize
+		ctx.printf("%v)\n", ctx.calculatedSize)
+	}
+
+	startLine(ctx, 0)
+	ctx.print("(Size implied by file size:         ")
+	if ctx.hasProfile {
+		ctx.print("n/a")
+	} else {
+		ctx.printf("%v", bitsLen)
+	}
+	ctx.print(")\n")
+
+	if !ctx.isCompressed && ctx.bitCount > 0 && ctx.rowStride > 0 && ctx.imgHeight > 0 {
+		printRowPadding(ctx)
+	}
+
+	if ctx.compressionType == "jpeg" || ctx.compressionType == "png" {
+		checkEmbeddedImage(ctx, bitsSectionSize)
+	}
+
+	if ctx.pixelFormatOverride != "" && ctx.printPixels {
+		if ctx.isCompressed {
+			startLine(ctx, 0)
+			ctx.print("(Pixel format override ignored for compressed images)\n")
+		} else {
+			printOverriddenPixels(ctx, bitsLen)
+			// The rest of the pixel analysis uses the format in the header,
+			// so don't do it.
+			ctx.printPixels = false
+		}
+	}
+
+	if !ctx.isCompressed {
+		if ctx.rowStride < 1 || ctx.rowStride > 1000000 {
+			ctx.printPixels = false
+		} else if bitsLen < ctx.calculatedSize {
+			completeRows := bitsLen / ctx.rowStride
+			ctx.warn("pixels", "Pixel data has %v complete rows but height field says %v (missing %v rows = %v bytes)",
+				completeRows, ctx.imgHeight, int64(ctx.imgHeight)-completeRows,
+				ctx.calculatedSize-bitsLen)
+			ctx.printPixels = false
+		} else if calculatedSizeValid(ctx) && bitsSectionSize > ctx.calculatedSize {
+			reportExtraPixelData(ctx, bitsSectionSize-ctx.calculatedSize)
+		}
+	}
+
+	if ctx.printPixels && ctx.rowMap {
+		printRowMap(ctx)
+	}
+
+	if ctx.printPixels {
+		saveSuppressOutput := ctx.suppressOutput
+		saveAlwaysShowWarnings := ctx.alwaysShowWarnings
+		if ctx.diffFromDefaults {
+			// Decode the pixels, so that problems are still reported, but
+			// don't show them.
+			startLine(ctx, 0)
+			ctx.print("(Pixels not shown, due to -diff-from-defaults)\n")
+			ctx.alwaysShowWarnings = !ctx.suppressOutput
+			ctx.suppressOutput = true
+		}
+
+		switch ctx.compressionType {
+		case "none":
+			printUncompressedPixels(ctx)
+		case "rle8", "rle4", "rle24":
+			printRLECompressedPixels(ctx, ctx.file.section(ctx.pos, ctx.fileSize))
+		case "huffman1d":
+			printHuffman1DBytes(ctx, ctx.file.bytes(ctx.pos, ctx.pos+huffman1DBytesNeeded(bitsLen)))
+		default:
+			startLine(ctx, 0)
+			ctx.print("(Don't know how to decode this type of bitmap.)\n")
+		}
+
+		ctx.suppressOutput = saveSuppressOutput
+		ctx.alwaysShowWarnings = saveAlwaysShowWarnings
+	} else if ctx.json != nil && ctx.topDown {
+		collectRLEStats(ctx)
+	}
+
+	if ctx.statistics {
+		ctx.stats = collectImageStatistics(ctx, allBits())
+	}
+
+	if ctx.checkUnusedBits && ctx.printPixels && ctx.compressionCode == bI_RGB &&
+		(ctx.bitCount == 16 || ctx.bitCount == 32) {
+		reportUnusedBits(ctx, allBits())
+	}
+
+	if ctx.alphaCheck && ctx.printPixels && !ctx.isCompressed {
+		reportAlphaChannel(ctx, allBits())
+	}
+
+	if ctx.complexity && ctx.printPixels && !ctx.isCompressed {
+		reportComplexity(ctx, allBits())
+	}
+
+	if ctx.pixelUniqueness && ctx.printPixels && !ctx.isCompressed {
+		reportPixelUniqueness(ctx, allBits())
+	}
+
+	if ctx.lzwEstimate && ctx.printPixels && !ctx.isCompressed {
+		reportLZWEstimate(ctx, allBits())
+	}
+
+	if ctx.duplicateRows && ctx.printPixels && !ctx.isCompressed {
+		reportDuplicateRows(ctx, allBits())
+	}
+
+	if ctx.findPixel && ctx.printPixels && !ctx.isCompressed {
+		reportFindPixel(ctx, allBits())
+	}
+
+	return nil
+}
+
+// Report the bytes after the last row of an uncompressed image. Some
+// encoders (Photoshop, for one) write a few extra bytes and include them in
+// SizeImage. Those are only noted; other extra bytes are a problem.
+func reportExtraPixelData(ctx *ctx_type, extraSize int64) {
+	const maxBytesShown = 16
+	if ctx.sizeImage != 0 && ctx.calculatedSize+extraSize <= int64(ctx.sizeImage) {
+		startLine(ctx, ctx.calculatedSize)
+		ctx.printf("(Pixel data has %v bytes extra after %v complete rows, included in SizeImage)\n",
+			extraSize, ctx.imgHeight)
+		return
+	}
+
+	ctx.warn("pixels", "Pixel data has %v bytes extra after %v complete rows",
+		extraSize, ctx.imgHeight)
+	n := extraSize
+	if n > maxBytesShown {
+		n = maxBytesShown
+	}
+	startLine(ctx, ctx.calculatedSize)
+	pos := ctx.pos + ctx.calculatedSize
+	ctx.printf("(First %v trailing bytes: % x)\n", n, ctx.file.bytes(pos, pos+n))
+}
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a}
+
+// For BI_JPEG and BI_PNG images, SizeImage is the size of the embedded
+// image, and is required. Check it against the size of the bits section,
+// and check that the data starts with the right signature.
+func checkEmbeddedImage(ctx *ctx_type, size int64) {
+	if ctx.sizeImage == 0 {
+		ctx.warn("compression", "biSizeImage should be set for JPEG/PNG compressed images")
+	} else if int64(ctx.sizeImage) > size {
+		ctx.warn("compression", "biSizeImage (%v) exceeds available bytes in file (%v) for embedded JPEG/PNG",
+			ctx.sizeImage, size)
+	}
+
+	// The signatures are no longer than the PNG signature.
+	headSize := int64(len(pngSignature))
+	if headSize > size {
+		headSize = size
+	}
+	d := ctx.file.bytes(ctx.pos, ctx.pos+headSize)
+
+	// For JPEG, the signature is the SOI marker, and the start of the next
+	// marker.
+	var sig []byte
+	if ctx.compressionType == "jpeg" {
+		sig = []byte{0xff, 0xd8, 0xff}
+	} else {
+		sig = pngSignature
+	}
+	name := strings.ToUpper(ctx.compressionType)
+	if bytes.HasPrefix(d, sig) {
+		startLine(ctx, 0)
+		ctx.printf("(%s signature confirmed)\n", name)
+		return
+	}
+
+	n := len(sig)
+	if n > len(d) {
+		n = len(d)
+	}
+	got := strings.TrimSpace(fmt.Sprintf("% x", d[:n]))
+	if got == "" {
+		got = "no data"
+	}
+	ctx.warn("compression", "Compression is BI_%s but pixel data does not start with %s signature (got: %s)",
+		name, name, got)
+}
+
+func inspectProfile(ctx *ctx_type, d []byte) {
+	defer enterSection(ctx, "profile")()
+	printSectionBanner(ctx, "Color profile", int64(len(d)))
+	startLine(ctx, 0)
+	ctx.printf("(%s)\n", iccProfileSummary(d))
+	inspectICCProfile(ctx, d)
+}
+
+func printWindows1252String(ctx *ctx_type, d []byte) {
+	for i := 0; i < len(d); i++ {
+		if d[i] == '\\' || d[i] == '"' {
+			// Printable ASCII characters that need escaped
+			ctx.printf("\\")
+		}
+		if d[i] >= 32 && d[i] <= 126 {
+			// Printable ASCII characters
+			ctx.printf("%c", d[i])
+		} else {
+			// Non-ASCII or non-printable characters
+			ctx.printf("\\x%02x", d[i])
+		}
+	}
+}
+
+// Display the filename of the linked profile.
+// The filename is supposed to be NUL-terminated, and use the Windows-1252
+// character set.
+func inspectLinkedProfile(ctx *ctx_type, d []byte) {
+	defer enterSection(ctx, "profile")()
+	printSectionBanner(ctx, "Linked color profile", int64(len(d)))
+	startLine(ctx, 0)
+	ctx.print("Filename: \"")
+	printWindows1252String(ctx, d)
+	ctx.print("\"\n")
+}
+
+// Try to identify the contents of a block of bytes that is not part of any
+// known section, by looking for known signatures. Returns "" if the contents
+// were not identified.
+func identifyBinaryContent(d []byte) string {
+	switch {
+	case len(d) == 0:
+		return ""
+	case bytes.HasPrefix(d, []byte("\x89PNG\r\n\x1a\n")):
+		return "PNG image"
+	case bytes.HasPrefix(d, []byte{0xff, 0xd8, 0xff, 0xe0}):
+		return "JPEG image (APP0/JFIF)"
+	case bytes.HasPrefix(d, []byte{0xff, 0xd8, 0xff, 0xe1}):
+		return "JPEG image (APP1/Exif)"
+	case bytes.HasPrefix(d, []byte{0xff, 0xd8, 0xff}):
+		return "JPEG image"
+	case len(d) >= 40 && bytes.Equal(d[36:40], []byte("acsp")):
+		return "ICC color profile"
+	case bytes.HasPrefix(d, []byte("Exif\x00\x00")):
+		return "Exif metadata"
+	case bytes.Contains(d, []byte("<?xpacket")):
+		return "XMP metadata"
+	case len(d) >= 18 && d[0] == 'B' && d[1] == 'M':
+		return "BMP image (thumbnail?)"
+	case len(d) >= 2 && d[0] == 0x1c && d[1] == 0x02:
+		return "IPTC metadata"
+	case bytes.Count(d, []byte{0}) == len(d):
+		return "all zero bytes"
+	}
+	return ""
+}
+
+// Report a block of bytes that is not part of any known section.
+func reportUnusedBytes(ctx *ctx_type, pos int64, n int64) {
+	startLineAbsolute(ctx, pos)
+	ctx.printf("----- %v unused bytes -----\n", n)
+
+	d := ctx.file.bytes(pos, pos+n)
+	ctx.gaps = append(ctx.gaps, gapInfo_type{start: pos, size: n,
+		content: classifyGapContent(d)})
+	if ctx.showGaps {
+		printGapDetails(ctx, ctx.gaps[len(ctx.gaps)-1])
+		return
+	}
+
+	content := identifyBinaryContent(d)
+	startLineAbsolute(ctx, pos)
+	if content != "" {
+		ctx.printf("(Contents: %s)\n", content)
+		return
+	}
+
+	if len(d) > 32 {
+		d = d[:32]
+	}
+	ctx.print("(Unidentified contents:")
+	for i := range d {
+		ctx.printf(" %02x", d[i])
+	}
+	if int64(len(d)) < n {
+		ctx.print(" ...")
+	}
+	ctx.print(")\n")
+}
+
+// Look for an ICC profile in the gap between the headers and the bitmap bits.
+// Photoshop puts one there, usually after a short tag, instead of using the
+// documented V5 profile fields.
+func inspectGapProfile(ctx *ctx_type, pos int64, n int64) {
+	d := ctx.file.bytes(pos, pos+n)
+
+	i := bytes.Index(d, []byte("acsp"))
+	if i < 36 {
+		return
+	}
+	d = d[i-36:]
+	profileSize := int64(binary.BigEndian.Uint32(d[0:4]))
+	if profileSize < 128 || profileSize > int64(len(d)) {
+		profileSize = int64(len(d))
+	}
+
+	ctx.hasNonStandardProfile = true
+	startLineAbsolute(ctx, pos+int64(i)-36)
+	ctx.print("(Non-standard ICC profile detected in gap, Photoshop-style embedding)\n")
+	startLineAbsolute(ctx, pos+int64(i)-36)
+	ctx.printf("(%s)\n", iccProfileSummary(d[:profileSize]))
+}
+
+// Prepare to read the file from r as it is needed. The sections are read
+// on demand, so r must stay usable until the inspection is done.
+func readBmpData(ctx *ctx_type, r io.ReadSeeker) error {
+	var err error
+
+	ctx.file, err = newFileData(r)
+	if err != nil {
+		return err
+	}
+	ctx.fileSize = ctx.file.size
+	return nil
+}
+
+// Open fileName, and prepare to read it. The caller must call
+// ctx.file.close when it is done with the file.
+func loadBmpFile(ctx *ctx_type, fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	err = readBmpData(ctx, f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	ctx.file.closer = f
+	return nil
+}
+
+func readBmp(ctx *ctx_type) (err error) {
+	var unaccountedRanges [][2]int64
+
+	startPos := ctx.pos
+	defer ctx.statusClear()
+	// The file is read as it is parsed, so a read error can happen anywhere.
+	defer func() {
+		if err == nil && ctx.file.err != nil {
+			err = ctx.file.err
+		}
+	}()
+
+	if ctx.fileSize-ctx.pos < 18 {
+		return errors.New("File is too small to be a BMP")
+	}
+
+	if ctx.noFileheader {
+		ctx.infoHeaderSize = getDWORD(ctx.file.bytes(ctx.pos, ctx.pos+4))
+		detectVersion(ctx)
+		printVersionDetected(ctx)
+	} else {
+		if string(ctx.file.bytes(ctx.pos, ctx.pos+2)) == "BA" {
+			return readBitmapArray(ctx)
+		}
+		if string(ctx.file.bytes(ctx.pos, ctx.pos+4)) == "RIFF" {
+			return readRIFFContainer(ctx)
+		}
+
+		// First read the "biSize" field, which tells us the BMP version.
+		ctx.infoHeaderSize = getDWORD(ctx.file.bytes(ctx.pos+14, ctx.pos+18))
+
+		ctx.statusPrint("FILEHEADER")
+		err = inspectFileheader(ctx, ctx.file.bytes(ctx.pos, ctx.pos+14))
+		if err != nil {
+			return err
+		}
+		ctx.pos += 14
+		ctx.accountedBytes += 14
+	}
+
+	if skipSection(ctx, "infoheader") {
+		// Nothing else can be inspected without the infoheader.
+		startLine(ctx, 0)
+		ctx.print("(Remaining sections skipped)\n")
+		return nil
+	}
+
+	ctx.statusPrint("INFOHEADER")
+	err = readInfoheader(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.accountedBytes += int64(ctx.infoHeaderSize)
+
+	validateBppCompression(ctx)
+
+	if ctx.bitCount > 8 && ctx.palNumEntries > 256 {
+		ctx.warn("palette", "palNumEntries=%v for bitCount=%v (palette only valid for indexed images)",
+			ctx.palNumEntries, ctx.bitCount)
+	}
+
+	if ctx.hasBitfieldsSegment {
+		if ctx.fileSize-ctx.pos < ctx.bitfieldsSegmentSize {
+			return errors.New("Unexpected end of file")
+		}
+		err = inspectBitfields(ctx, ctx.file.bytes(ctx.pos, ctx.pos+ctx.bitfieldsSegmentSize))
+		if err != nil {
+			return err
+		}
+		ctx.pos += ctx.bitfieldsSegmentSize
+		ctx.accountedBytes += ctx.bitfieldsSegmentSize
+	}
+
+	if ctx.palSizeInBytes > 0 {
+		// If the file ends in the middle of the color table, inspect as
+		// many entries as we can.
+		expectedEntries := ctx.palNumEntries
+		if ctx.fileSize-ctx.pos < int64(ctx.palSizeInBytes) {
+			availableEntries := int((ctx.fileSize - ctx.pos) / int64(ctx.palBytesPerEntry))
+			if availableEntries < ctx.palNumEntries {
+				ctx.palNumEntries = availableEntries
+			}
+			ctx.palSizeInBytes = ctx.palNumEntries * ctx.palBytesPerEntry
+		}
+		ctx.palPos = ctx.pos
+		ctx.statusPrint(fmt.Sprintf("Color table (%v entries)", ctx.palNumEntries))
+		err = inspectColorTable(ctx, ctx.file.bytes(ctx.pos, ctx.pos+int64(ctx.palSizeInBytes)))
+		if err != nil {
+			return err
+		}
+		if ctx.palNumEntries < expectedEntries {
+			ctx.warn("palette", "Palette truncated after %v entries, expected %v",
+				ctx.palNumEntries, expectedEntries)
+		}
+		ctx.pos += int64(ctx.palSizeInBytes)
+		ctx.accountedBytes += int64(ctx.palSizeInBytes)
+	}
+
+	if ctx.noFileheader {
+		// Without a fileheader, the bits immediately follow the color table.
+		ctx.bfOffBits = uint32(ctx.pos)
+	}
+
+	// Is the bfOffBits pointer sensible?
+	if int64(ctx.bfOffBits) < ctx.pos || int64(ctx.bfOffBits) > ctx.fileSize {
+		return errors.New("Bad bfOffBits value")
+	}
+
+	var unusedBytes int64
+	unusedBytes = int64(ctx.bfOffBits) - ctx.pos
+	leaveSection := enterSection(ctx, "")
+	if unusedBytes > 0 {
+		reportUnusedBytes(ctx, ctx.pos, unusedBytes)
+		inspectGapProfile(ctx, ctx.pos, unusedBytes)
+	} else if !ctx.noFileheader {
+		startLine(ctx, 0)
+		ctx.printf("(No gap between headers/palette and bits: bfOffBits=%v is optimal)\n",
+			ctx.bfOffBits)
+	}
+	if ctx.xmp {
+		inspectXMP(ctx, ctx.pos, ctx.file.bytes(ctx.pos, ctx.pos+unusedBytes))
+	}
+	leaveSection()
+	ctx.pos += unusedBytes
+	ctx.accountedBytes += unusedBytes
+
+	if skipSection(ctx, "bits") {
+		// We don't know where the bits end, but we may know where the
+		// profile starts.
+		if !ctx.hasProfile || ctx.pos > ctx.profileOffset {
+			return nil
+		}
+		ctx.accountedBytes += ctx.profileOffset - ctx.pos
+		ctx.pos = ctx.profileOffset
+	} else {
+		// Assume the rest of the file contains the bitmap bits
+		ctx.statusPrint(fmt.Sprintf("Bitmap bits (%s)", humanBytes(ctx.fileSize-ctx.pos)))
+		err = inspectBits(ctx)
+		if err != nil {
+			return err
+		}
+
+		if ctx.actualBitsSize < 1 {
+			return nil
+		}
+
+		ctx.pos += ctx.actualBitsSize
+		ctx.accountedBytes += ctx.actualBitsSize
+	}
+
+	// The rest of the output, other than the profile, is not part of any
+	// section.
+	defer enterSection(ctx, "")()
+
+	if ctx.hasProfile {
+		if ctx.profileOffset > ctx.fileSize {
+			return errors.New("Invalid color profile location")
+		}
+		if ctx.pos < ctx.profileOffset {
+			reportUnusedBytes(ctx, ctx.pos, ctx.profileOffset-ctx.pos)
+			unaccountedRanges = append(unaccountedRanges, [2]int64{ctx.pos, ctx.profileOffset})
+			ctx.pos = ctx.profileOffset
+		} else if ctx.pos > ctx.profileOffset {
+			return errors.New("Invalid color profile location")
+		}
+
+		if ctx.pos+ctx.profileSize > ctx.fileSize {
+			return errors.New("Invalid color profile size")
+		}
+
+		if !skipSection(ctx, "profile") {
+			if ctx.profileIsLinked {
+				inspectLinkedProfile(ctx, ctx.file.bytes(ctx.pos, ctx.pos+ctx.profileSize))
+			} else {
+				inspectProfile(ctx, ctx.file.bytes(ctx.pos, ctx.pos+ctx.profileSize))
+			}
+		}
+		ctx.pos += ctx.profileSize
+		ctx.accountedBytes += ctx.profileSize
+	}
+
+	if ctx.pos < ctx.fileSize {
+		reportUnusedBytes(ctx, ctx.pos, ctx.fileSize-ctx.pos)
+		unaccountedRanges = append(unaccountedRanges, [2]int64{ctx.pos, ctx.fileSize})
+	}
+
+	if ctx.showGaps {
+		printGapSummary(ctx)
+	}
+
+	unaccountedBytes := ctx.fileSize - startPos - ctx.accountedBytes
+	if unaccountedBytes > 0 {
+		startLineAbsolute(ctx, ctx.fileSize)
+		ctx.printf("(Unaccounted bytes: %v", unaccountedBytes)
+		for i, r := range unaccountedRanges {
+			if i == 0 {
+				ctx.print(", at offsets ")
+			} else {
+				ctx.print(", ")
+			}
+			ctx.printf("%v-%v", r[0], r[1]-1)
+		}
+		ctx.print(")\n")
+	}
+
+	if ctx.thumbnailPos > 0 && ctx.inspectThumbnail {
+		return readThumbnail(ctx)
+	}
+
+	return nil
+}
+
+// Show the section being parsed on a status line, on stderr. Each status
+// message overwrites the previous one.
+func (ctx *ctx_type) statusPrint(msg string) {
+	if !ctx.showStatus {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r[Parsing %s...]\x1b[K", msg)
+	ctx.statusPending = true
+}
+
+// Erase the status line, if there is one.
+func (ctx *ctx_type) statusClear() {
+	if !ctx.statusPending {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+	ctx.statusPending = false
+}
+
+// Implements the -count-rows option.
+func printRowCount(ctx *ctx_type) error {
+	ctx.suppressOutput = true
+	err := readBmp(ctx)
+	ctx.suppressOutput = false
+	if err != nil {
+		return err
+	}
+
+	var result string
+	switch ctx.compressionType {
+	case "none":
+		// The number of rows is always the same as the height.
+		ctx.printf("Rows: %v (height field: %v) MATCH\n", ctx.imgHeight, ctx.imgHeight)
+	case "rle4", "rle8", "rle24":
+		if !ctx.printPixels {
+			return errors.New("Can't decode the RLE data")
+		}
+		result = "MISMATCH"
+		if ctx.rleRowsDecoded == ctx.imgHeight {
+			result = "MATCH"
+		}
+		ctx.printf("Encoded rows: %v (imgHeight: %v) %s\n", ctx.rleRowsDecoded,
+			ctx.imgHeight, result)
+	default:
+		return errors.New("Can't count the rows of this type of image")
+	}
+	return nil
+}
+
+// Report whether f appears to be an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func main2(ctx *ctx_type, args []string) error {
+	var err error
+
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+
+	fs.BoolVar(&ctx.specRefs, "spec-refs", false,
+		"Show a documentation reference for each field")
+	fs.BoolVar(&ctx.rawBytes, "raw-bytes", false,
+		"Show the raw bytes of each infoheader field")
+	fs.BoolVar(&ctx.showRawInfoheader, "show-raw-infoheader", false,
+		"Show the bytes of the infoheader before parsing it")
+	fs.BoolVar(&ctx.rowChecksums, "row-checksums", false,
+		"Print a CRC-32 of each row's bytes")
+	fs.BoolVar(&ctx.rowChecksumsOnly, "row-checksums-only", false,
+		"Print a CRC-32 of each row's bytes, instead of the pixel values")
+	fs.BoolVar(&ctx.rowMap, "row-map", false,
+		"Print a table of the file offset of each row")
+	fs.BoolVar(&ctx.annotateRLE, "annotate-rle", false,
+		"Print the number of pixels in each row of an RLE-compressed image")
+	fs.Var(&ctx.decodeRLE, "decode-rle",
+		"Print each pixel of an RLE-compressed image, instead of the runs (=replace, the default) or after each run (=inline)")
+	fs.BoolVar(&ctx.missingEOBMPOK, "missing-eobmp-ok", false,
+		"Don't report RLE-compressed data that ends without an EOBMP code")
+	fs.BoolVar(&ctx.checkUnusedBits, "check-unused-bits", false,
+		"Count the 16- and 32-bit pixels that use the unused bits")
+	fs.BoolVar(&ctx.checkConventions, "check-conventions", false,
+		"Compare the palette to the conventional Windows palette")
+	fs.StringVar(&ctx.pixelFormatOverride, "interpret-as-format", "",
+		"Display the pixels as if they were in the given format: "+pixelFormatNames())
+	fs.BoolVar(&ctx.pixelOffsets, "pixel-offsets", false,
+		"Print the file offset of each pixel")
+	fs.BoolVar(&ctx.showPaletteRGB, "show-palette-rgb", false,
+		"Print the color of each pixel of an indexed image, after its palette index")
+	fs.BoolVar(&ctx.noWidthLimit, "no-width-limit", false,
+		"With -show-palette-rgb, show the colors even for images wider than 64 pixels")
+	fs.BoolVar(&ctx.decodePixels, "decode-pixels", false,
+		"Print the RGB(A) components of each pixel of a 16- or 32-bit image")
+	fs.BoolVar(&ctx.palettePreview, "palette-preview", false,
+		"Show the palette colors as colored blocks, using ANSI escape codes")
+	fs.StringVar(&ctx.paletteSort, "palette-sort", "index",
+		"Also show the palette sorted by: "+strings.Join(paletteSortModes, ", "))
+	fs.BoolVar(&ctx.checkSRGB, "check-srgb", false,
+		"Check that the gamma and endpoint fields are consistent with CSType")
+	fs.BoolVar(&ctx.alphaCheck, "alpha-check", false,
+		"Examine the alpha channel of 32-bit images")
+	fs.BoolVar(&ctx.rleAnalysis, "rle-analysis", false,
+		"Print an analysis of the tokens in RLE-compressed images")
+	fs.BoolVar(&ctx.complexity, "complexity", false,
+		"Print measures of the image's complexity")
+	fs.BoolVar(&ctx.pixelUniqueness, "pixel-uniqueness", false,
+		"Print the percentage of pixels whose color appears only once")
+	fs.BoolVar(&ctx.lzwEstimate, "lzw-estimate", false,
+		"Estimate the size of the pixels if they were compressed with LZW")
+	fs.BoolVar(&ctx.duplicateRows, "detect-duplicate-rows", false,
+		"Report rows that are identical to the previous row")
+	findPixel := fs.String("find-pixel", "",
+		"Find the pixels of the given color, RRGGBB")
+	fs.BoolVar(&ctx.diffFromDefaults, "diff-from-defaults", false,
+		"Only show the header fields whose value differs from the default")
+	useColor := fs.Bool("color", false, "Use ANSI colors (default if output is a terminal)")
+	noColor := fs.Bool("no-color", false, "Don't use ANSI colors")
+	ctx.skipSections = make(sectionList_type)
+	fs.Var(ctx.skipSections, "skip-section",
+		"Don't display the named section (may be repeated)")
+	fs.StringVar(&ctx.showOnly, "section", "",
+		"Display only the named section")
+	fs.BoolVar(&ctx.tableHeaders, "table-headers", false,
+		"Display the fileheader and infoheader as tables")
+	fs.BoolVar(&ctx.inspectThumbnail, "inspect-thumbnail", false,
+		"Inspect a possible thumbnail image pointed to by the bfReserved fields")
+	fs.BoolVar(&ctx.xmp, "xmp", false,
+		"Look for XMP metadata between the headers and the bitmap bits")
+	fs.BoolVar(&ctx.showGaps, "show-gaps", false,
+		"Print details about each block of unused bytes")
+	fs.BoolVar(&ctx.statistics, "statistics", false,
+		"Print statistics about the image and its pixels")
+	noStatus := fs.Bool("no-status", false,
+		"Don't show the section being parsed on stderr")
+	countRows := fs.Bool("count-rows", false,
+		"Only print the number of rows encoded in the image")
+	describe := fs.Bool("describe", false,
+		"Only print a one-sentence description of the image")
+	outputFormat := fs.String("format", "text",
+		"Output format: \"text\" or \"json\"")
+	colorProfileType := fs.Bool("color-profile-type", false,
+		"Only print a summary of the embedded color profile")
+	formatCheck := fs.String("format-check", "",
+		"Check the file against a format profile: "+validationProfileNames())
+	checkEncoder := fs.Bool("check-encoder", false,
+		"Guess which application wrote the file, from quirks in its headers")
+	fs.BoolVar(&ctx.validate, "validate", false,
+		"Print a validity score, based on the problems found")
+	repair := fs.Bool("repair", false,
+		"Write a copy of the file, with fixable errors fixed, to the file named by the second argument")
+	dumpPalette := fs.String("dump-palette", "",
+		"Write the palette to the named text file")
+	dumpPaletteCSS := fs.String("dump-palette-css", "",
+		"Write the palette to the named file, as CSS custom properties")
+	cStructName := fs.String("generate-c-struct", "",
+		"Instead of the usual output, print the file as a C array with the given name")
+	batchJSONL := fs.Bool("batch-jsonl", false,
+		"Instead of the usual output, print a line of JSON for each file named")
+	outputPath := fs.String("output", "",
+		"With -batch-jsonl or -generate-c-struct, write to the named file instead of stdout")
+	createBmp := fs.String("create-bmp", "",
+		"Instead of inspecting a file, create a BMP file with dimensions WxHxBPP")
+	compareHeaders := fs.Bool("compare-headers", false,
+		"Instead of inspecting a file, print the header fields that differ between two files")
+	hexDiff := fs.Bool("hex-diff", false,
+		"Instead of inspecting a file, print the bytes of two files side by side")
+	fs.BoolVar(&ctx.noLimit, "no-limit", false,
+		"Don't limit the length of the -hex-diff output")
+	stopOnError := fs.Bool("stop-on-error", false,
+		"With multiple files, stop at the first file that can't be inspected")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		return errors.New("Usage error")
+	}
+	if _, ok := pixelFormats[ctx.pixelFormatOverride]; ctx.pixelFormatOverride != "" && !ok {
+		return errors.New("Unknown pixel format (valid formats: " + pixelFormatNames() + ")")
+	}
+	if *formatCheck != "" {
+		if _, err = findValidationProfile(*formatCheck); err != nil {
+			return err
+		}
+	}
+	if *outputFormat != "text" && *outputFormat != "json" {
+		return errors.New("Unknown output format (valid formats: text, json)")
+	}
+	if !isPaletteSortMode(ctx.paletteSort) {
+		return errors.New("Unknown palette sort order (valid orders: " +
+			strings.Join(paletteSortModes, ", ") + ")")
+	}
+	if ctx.showOnly != "" && !isSectionName(ctx.showOnly) {
+		return errors.New("Unknown section name (valid names: " +
+			strings.Join(sectionNames, ", ") + ")")
+	}
+	if *findPixel != "" {
+		ctx.findPixelColor, err = parseFindPixelColor(*findPixel)
+		if err != nil {
+			return err
+		}
+		ctx.findPixel = true
+	}
+	if *cStructName != "" && !cIdentifier.MatchString(*cStructName) {
+		return errors.New("-generate-c-struct name is not a valid C identifier")
+	}
+	if *batchJSONL {
+		return writeBatchJSONL(fs.Args(), *outputPath)
+	}
+	if *compareHeaders {
+		if fs.NArg() < 2 {
+			return errors.New("Usage error")
+		}
+		return printHeaderChanges(ctx, fs.Arg(0), fs.Arg(1))
+	}
+	ctx.fileName = fs.Arg(0)
+	if ctx.rowChecksumsOnly {
+		ctx.rowChecksums = true
+	}
+	if *noColor {
+		ctx.useColor = false
+	} else if *useColor {
+		ctx.useColor = true
+	} else {
+		ctx.useColor = isTerminal(os.Stdout)
+	}
+
+	// If stdout is the same terminal, the status line would get mixed up
+	// with the normal output.
+	ctx.tableUnicode = isTerminal(os.Stdout)
+	ctx.showStatus = !*noStatus && isTerminal(os.Stderr) && !isTerminal(os.Stdout)
+
+	if *hexDiff {
+		if fs.NArg() < 2 {
+			return errors.New("Usage error")
+		}
+		var files [2][]byte
+		for i := range files {
+			files[i], err = ioutil.ReadFile(fs.Arg(i))
+			if err != nil {
+				return err
+			}
+		}
+		ctx.printHexDiff(files[0], files[1])
+		return nil
+	}
+
+	if *createBmp != "" {
+		width, height, bitCount, err := parseCreateDimensions(*createBmp)
+		if err != nil {
+			return err
+		}
+		return createBMP(width, height, bitCount, ctx.fileName)
+	}
+
+	if *repair && fs.NArg() < 2 {
+		return errors.New("Usage error")
+	}
+
+	// Inspect the file named by ctx.fileName.
+	inspectFile := func(ctx *ctx_type) error {
+		var err error
+
+		ctx.printPixels = true
+		ctx.compressionType = "none" // default
+
+		// The sections of the file are read as they are needed.
+		err = loadBmpFile(ctx, ctx.fileName)
+		if err != nil {
+			return err
+		}
+		defer ctx.file.close()
+
+		if *colorProfileType {
+			// Only print the profile summary.
+			ctx.suppressOutput = true
+			err = readBmp(ctx)
+			ctx.suppressOutput = false
+			if err != nil {
+				return err
+			}
+			if ctx.hasProfile && !ctx.profileIsLinked && ctx.profileOffset > 0 &&
+				ctx.profileOffset+ctx.profileSize <= ctx.fileSize {
+				d := ctx.file.bytes(ctx.profileOffset, ctx.profileOffset+ctx.profileSize)
+				ctx.printf("%s\n", iccProfileSummary(d))
+			} else {
+				ctx.print("(No embedded color profile)\n")
+			}
+			return nil
+		}
+
+		if *countRows {
+			return printRowCount(ctx)
+		}
+
+		if *outputFormat == "json" {
+			return printInspectionJSON(ctx)
+		}
+		if *describe {
+			ctx.suppressOutput = true
+			err = readBmp(ctx)
+			ctx.suppressOutput = false
+			if err != nil {
+				return err
+			}
+			ctx.printf("%s\n", describeImage(ctx))
+			return nil
+		}
+
+		if *cStructName != "" {
+			ctx.suppressOutput = true
+			err = readBmp(ctx)
+			ctx.suppressOutput = false
+			if err != nil {
+				return err
+			}
+			src := generateCStruct(ctx, *cStructName)
+			if *outputPath != "" {
+				return ioutil.WriteFile(*outputPath, []byte(src), 0666)
+			}
+			ctx.print(src)
+			return nil
+		}
+
+		c, err := parseBmp(ctx)
+		printErr := Print(c, ctx.writer())
+		if err == nil {
+			err = printErr
+		}
+
+		if err == nil && *checkEncoder {
+			printLikelyEncoder(ctx)
+		}
+
+		if err == nil && *formatCheck != "" {
+			err = validateProfile(ctx, *formatCheck)
+		}
+
+		if ctx.validate {
+			if err != nil {
+				ctx.score.deduct("error")
+			}
+			printValidityScore(ctx)
+		}
+
+		if err == nil && (*dumpPalette != "" || *dumpPaletteCSS != "") {
+			notePaletteDumpSize(ctx)
+			if *dumpPalette != "" {
+				err = writePaletteText(ctx, *dumpPalette)
+			}
+			if err == nil && *dumpPaletteCSS != "" {
+				err = writePaletteCSS(ctx, *dumpPaletteCSS)
+			}
+		}
+
+		if err == nil && *repair {
+			err = repairBmp(ctx, fs.Arg(1))
+		}
+		return err
+	}
+
+	if *repair || fs.NArg() == 1 {
+		return inspectFile(ctx)
+	}
+
+	// These options produce output that can't be divided among several
+	// files.
+	switch {
+	case *outputFormat == "json":
+		return errors.New("-format=json can only be used with one file")
+	case *cStructName != "" && *outputPath != "":
+		return errors.New("-generate-c-struct with -output can only be used with one file")
+	case *dumpPalette != "" || *dumpPaletteCSS != "":
+		return errors.New("-dump-palette and -dump-palette-css can only be used with one file")
+	}
+	return inspectFiles(ctx, fs.Args(), *stopOnError, inspectFile)
+}
+
+// Main runs the bmpinspect command, with the given command-line arguments
+// (not including the program name).
+func Main(args []string) error {
+	// For compatibility, if the first argument isn't a subcommand name, it
+	// is the start of the arguments for the "inspect" subcommand.
+	var sc subcommand_type = inspectCmd_type{}
+	if len(args) > 0 {
+		c, ok := subcommands[args[0]]
+		if ok {
+			sc = c
+			args = args[1:]
+		}
+	}
+
+	return sc.run(args)
+}
@@ -0,0 +1,264 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/bmpinspect_test.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "encoding/binary"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+// Run f, and return what it printed to stdout.
+func captureOutput(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saveStdout := os.Stdout
+	os.Stdout = w
+	f()
+	os.Stdout = saveStdout
+	w.Close()
+
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// Make a BMP file from an infoheader and the data that follows it (the
+// palette and the bits). bfOffBits points to just after the palette, which
+// is palSize bytes.
+func makeTestBmp(ih []byte, palSize int, rest []byte) []byte {
+	d := make([]byte, 14, 14+len(ih)+len(rest))
+	copy(d, "BM")
+	d = append(d, ih...)
+	d = append(d, rest...)
+	binary.LittleEndian.PutUint32(d[2:6], uint32(len(d)))
+	binary.LittleEndian.PutUint32(d[10:14], uint32(14+len(ih)+palSize))
+	return d
+}
+
+// Make a BITMAPINFOHEADER, padded with zeroes to headerSize bytes.
+func makeTestInfoHeader(headerSize int, width, height int32, bitCount uint16,
+	compression uint32) []byte {
+	ih := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(ih[0:4], uint32(headerSize))
+	binary.LittleEndian.PutUint32(ih[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(ih[8:12], uint32(height))
+	binary.LittleEndian.PutUint16(ih[12:14], 1)
+	binary.LittleEndian.PutUint16(ih[14:16], bitCount)
+	binary.LittleEndian.PutUint32(ih[16:20], compression)
+	return ih
+}
+
+// Inspect the BMP file in d, as the inspect subcommand does, and return
+// what it printed. A panic is not recovered, so it fails the test.
+func inspectTestBmp(t *testing.T, d []byte) (string, error) {
+	var buf bytes.Buffer
+	ctx := new(ctx_type)
+	ctx.out = &buf
+	ctx.printPixels = true
+	ctx.compressionType = "none"
+	err := readBmpData(ctx, bytes.NewReader(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = readBmp(ctx)
+	return buf.String(), err
+}
+
+// Make a ctx for decoding RLE24 data for a width×height image, as if the
+// bits started at file offset 0.
+func newRLE24TestCtx(width, height int) *ctx_type {
+	ctx := new(ctx_type)
+	ctx.printPixels = true
+	ctx.bmpVerID = "os2v2"
+	ctx.bitCount = 24
+	ctx.compressionCode = bI_RLE24
+	ctx.compressionType = "rle24"
+	ctx.isCompressed = true
+	ctx.imgWidth = width
+	ctx.imgHeight = height
+	return ctx
+}
+
+func TestRLE24Decoding(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		data          []byte
+		want          string
+	}{
+		{
+			name:  "compressed run of 3 pixels",
+			width: 3, height: 1,
+			data: []byte{
+				3, 0x11, 0x22, 0x33, // 3 pixels of B=11 G=22 R=33
+				0, 1, // EOBMP
+			},
+			want: "      0: row 0: 3{332211} EOBMP [6 bytes]\n" +
+				"      6: (Max runs in a single row: 1)\n" +
+				"      6: (Total run tokens: 1)\n" +
+				"      6: (Average runs per row: 1.0)\n",
+		},
+		{
+			// 12 bytes of pixel data, so no padding.
+			name:  "uncompressed run of 4 pixels",
+			width: 4, height: 1,
+			data: []byte{
+				0, 4,
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+				0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+				0, 1,
+			},
+			want: "      0: row 0: u4{030201 060504 090807 0c0b0a} EOBMP [16 bytes]\n" +
+				"     16: (Max runs in a single row: 1)\n" +
+				"     16: (Total run tokens: 1)\n" +
+				"     16: (Average runs per row: 1.0)\n",
+		},
+		{
+			// 9 bytes of pixel data, followed by a padding byte. The pixels
+			// straddle the 2-byte units the decoder reads.
+			name:  "uncompressed run of 3 pixels, padded",
+			width: 3, height: 1,
+			data: []byte{
+				0, 3,
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+				0x07, 0x08, 0x09, 0xee,
+				0, 1,
+			},
+			want: "      0: row 0: u3{030201 060504 090807} EOBMP [14 bytes]\n" +
+				"     14: (Max runs in a single row: 1)\n" +
+				"     14: (Total run tokens: 1)\n" +
+				"     14: (Average runs per row: 1.0)\n",
+		},
+		{
+			// A 5-pixel uncompressed run is 15 bytes plus padding. The
+			// compressed run after it must start after the padding byte.
+			name:  "uncompressed run of 5 pixels, then compressed run",
+			width: 7, height: 1,
+			data: []byte{
+				0, 5,
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+				0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0xee,
+				2, 0x10, 0x11, 0x12,
+				0, 1,
+			},
+			want: "      0: row 0: u5{030201 060504 090807 0c0b0a 0f0e0d} 2{121110} EOBMP [24 bytes]\n" +
+				"     24: (Max runs in a single row: 2)\n" +
+				"     24: (Total run tokens: 2)\n" +
+				"     24: (Average runs per row: 2.0)\n",
+		},
+		{
+			name:  "EOL followed by more data",
+			width: 2, height: 2,
+			data: []byte{
+				2, 0x11, 0x22, 0x33,
+				0, 0, // EOL
+				2, 0x44, 0x55, 0x66,
+				0, 1,
+			},
+			want: "      0: row 1: 2{332211} EOL [6 bytes]\n" +
+				"      6: row 0: 2{665544} EOBMP [6 bytes]\n" +
+				"     12: (Max runs in a single row: 1)\n" +
+				"     12: (Total run tokens: 2)\n" +
+				"     12: (Average runs per row: 1.0)\n",
+		},
+		{
+			name:  "DELTA",
+			width: 4, height: 2,
+			data: []byte{
+				1, 0x11, 0x22, 0x33,
+				0, 2, 2, 1, // DELTA: right 2, up 1
+				1, 0x44, 0x55, 0x66,
+				0, 1,
+			},
+			want: "      0: row 1: 1{332211} DELTA(2,1) [8 bytes]\n" +
+				"      8: row 0: 1{665544} EOBMP [6 bytes]\n" +
+				"     14: (Max runs in a single row: 1)\n" +
+				"     14: (Total run tokens: 2)\n" +
+				"     14: (Average runs per row: 1.0)\n",
+		},
+	}
+
+	for _, tc := range tests {
+		ctx := newRLE24TestCtx(tc.width, tc.height)
+		got := captureOutput(t, func() {
+			printRLECompressedPixels(ctx, bytes.NewReader(tc.data))
+		})
+		if got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// A biHeight of -2147483648 can't be negated, so the calculated size of the
+// bits is negative. It must not be used to find extra pixel data.
+func TestMinimumHeight(t *testing.T) {
+	ih := makeTestInfoHeader(40, 1, -2147483648, 24, bI_RGB)
+	out, _ := inspectTestBmp(t, makeTestBmp(ih, 0, make([]byte, 8)))
+	if strings.Contains(out, "extra") {
+		t.Errorf("extra pixel data reported:\n%s", out)
+	}
+}
+
+// An embedded profile that is said to start after the end of the file.
+func TestProfilePastEOF(t *testing.T) {
+	ih := makeTestInfoHeader(124, 1, 1, 24, bI_RGB)
+	binary.LittleEndian.PutUint32(ih[56:60], 0x4d424544) // PROFILE_EMBEDDED
+	binary.LittleEndian.PutUint32(ih[112:116], 10000)    // ProfileData
+	binary.LittleEndian.PutUint32(ih[116:120], 100)      // ProfileSize
+	_, err := inspectTestBmp(t, makeTestBmp(ih, 0, make([]byte, 8)))
+	if err == nil || err.Error() != "Invalid color profile location" {
+		t.Errorf("got error %v, want \"Invalid color profile location\"", err)
+	}
+}
+
+// The EOBMP marker after the last row's EOL is on a pseudo-row of its own,
+// which is not annotated, and has no checksum.
+func TestRLEPseudoRow(t *testing.T) {
+	ctx := newRLE24TestCtx(3, 1)
+	ctx.annotateRLE = true
+	ctx.rowChecksums = true
+	got := captureOutput(t, func() {
+		printRLECompressedPixels(ctx, bytes.NewReader([]byte{
+			3, 0x11, 0x22, 0x33, // 3 pixels
+			0, 0, // EOL
+			0, 1, // EOBMP
+		}))
+	})
+	want := "      0: row 0: 3{332211} EOL (3 pixels) [6 bytes] crc32=0xebf98c1c\n" +
+		"      6: row n/a: EOBMP [2 bytes]\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Inspect damaged versions of the golden files: each one truncated at every
+// length, and with each byte replaced by a few extreme values. Batch mode
+// recovers from panics, but this test doesn't, so a crash fails it.
+func TestDamagedFiles(t *testing.T) {
+	names := []string{"os2v1.bmp", "pal4.bmp", "rgb24.bmp", "v5_32.bmp"}
+	for _, name := range names {
+		d, err := ioutil.ReadFile(filepath.Join(testDataDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < len(d); n++ {
+			inspectTestBmp(t, d[:n])
+		}
+		for i := range d {
+			for _, v := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff} {
+				damaged := append([]byte(nil), d...)
+				damaged[i] = v
+				inspectTestBmp(t, damaged)
+			}
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/compare.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "errors"
+import "flag"
+import "fmt"
+import "reflect"
+import "io/ioutil"
+import "github.com/jsummers/bmpinspect/pkg/bmpinspect"
+
+// A property of a BMP file that the "compare" subcommand compares.
+type compareField_type struct {
+	name     string
+	getValue func(ctx *ctx_type) interface{}
+}
+
+var compareFields = []compareField_type{
+	{"File size", func(ctx *ctx_type) interface{} { return ctx.fileSize }},
+	{"Version", func(ctx *ctx_type) interface{} { return ctx.bmpVerName }},
+	{"Info header size", func(ctx *ctx_type) interface{} { return ctx.infoHeaderSize }},
+	{"Width", func(ctx *ctx_type) interface{} { return ctx.imgWidth }},
+	{"Height", func(ctx *ctx_type) interface{} { return ctx.imgHeight }},
+	{"Top-down", func(ctx *ctx_type) interface{} { return ctx.topDown }},
+	{"BitCount", func(ctx *ctx_type) interface{} { return ctx.bitCount }},
+	{"Compression", func(ctx *ctx_type) interface{} { return ctx.compressionCode }},
+	{"bfOffBits", func(ctx *ctx_type) interface{} { return ctx.bfOffBits }},
+	{"Palette entries", func(ctx *ctx_type) interface{} { return ctx.palNumEntries }},
+	{"Has color profile", func(ctx *ctx_type) interface{} { return ctx.hasProfile }},
+}
+
+func getPaletteBytes(ctx *ctx_type) []byte {
+	return ctx.file.bytes(ctx.palPos, ctx.palPos+int64(ctx.palSizeInBytes))
+}
+
+// "compare": Print the differences between two BMP files, in their
+// structure and in their pixels.
+func (compareCmd_type) run(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		return errors.New("Usage error")
+	}
+
+	// The comparison is printed with ctx.
+	ctx := new(ctx_type)
+	var ctxs [2]*ctx_type
+	for i := range ctxs {
+		var err error
+		ctxs[i], err = readBmpQuietly(fs.Arg(i))
+		if err != nil {
+			return fmt.Errorf("%s: %v", fs.Arg(i), err)
+		}
+		defer ctxs[i].file.close()
+	}
+
+	ctx.print("----- Structure -----\n")
+	numDiffs := 0
+	for _, f := range compareFields {
+		v1 := f.getValue(ctxs[0])
+		v2 := f.getValue(ctxs[1])
+		if v1 != v2 {
+			ctx.printf("%s: %v vs. %v\n", f.name, v1, v2)
+			numDiffs++
+		}
+	}
+	if ctxs[0].palNumEntries == ctxs[1].palNumEntries &&
+		!bytes.Equal(getPaletteBytes(ctxs[0]), getPaletteBytes(ctxs[1])) {
+		ctx.print("Palette: contents differ\n")
+		numDiffs++
+	}
+	if numDiffs == 0 {
+		ctx.print("(No differences)\n")
+	}
+
+	ctx.print("----- Pixels -----\n")
+	if ctxs[0].imgWidth != ctxs[1].imgWidth || ctxs[0].imgHeight != ctxs[1].imgHeight {
+		ctx.print("(Images have different dimensions; pixels not compared)\n")
+		return nil
+	}
+	var pix [2][]byte
+	for i := range pix {
+		var err error
+		pix[i], err = decodeImageRGB(ctxs[i])
+		if err != nil {
+			ctx.printf("(%s: %v; pixels not compared)\n", fs.Arg(i), err)
+			return nil
+		}
+	}
+
+	width := ctxs[0].imgWidth
+	numPixels := len(pix[0]) / 3
+	numDiffPixels := 0
+	firstDiff := -1
+	for i := 0; i < numPixels; i++ {
+		if !bytes.Equal(pix[0][i*3:i*3+3], pix[1][i*3:i*3+3]) {
+			if firstDiff < 0 {
+				firstDiff = i
+			}
+			numDiffPixels++
+		}
+	}
+	if numDiffPixels == 0 {
+		ctx.print("(All pixels are the same color)\n")
+		return nil
+	}
+	ctx.printf("%v of %v pixels differ\n", numDiffPixels, numPixels)
+	p1 := pix[0][firstDiff*3 : firstDiff*3+3]
+	p2 := pix[1][firstDiff*3 : firstDiff*3+3]
+	ctx.printf("First difference at (%d,%d): %02x%02x%02x vs. %02x%02x%02x\n",
+		firstDiff%width, firstDiff/width, p1[0], p1[1], p1[2], p2[0], p2[1], p2[2])
+	return nil
+}
+
+// A header field whose value differs between two files.
+type fieldChange struct {
+	name          string
+	before, after string
+}
+
+// Append the fields of the struct v to the list of names, and to the map of
+// values, flattening any embedded structs.
+func collectStructFields(v reflect.Value, prefix string, names *[]string,
+	values map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			collectStructFields(v.Field(i), prefix, names, values)
+			continue
+		}
+		name := prefix + t.Field(i).Name
+		*names = append(*names, name)
+		values[name] = fmt.Sprintf("%v", v.Field(i).Interface())
+	}
+}
+
+// Return the header fields of a file, in the order they appear in the file,
+// and a map from field name to value. The fileheader fields have a "bf"
+// prefix. The infoheader fields are not prefixed, so that the fields that
+// different versions have in common have the same name.
+func headerFields(rpt *bmpinspect.Report) ([]string, map[string]string) {
+	var names []string
+	values := make(map[string]string)
+	collectStructFields(reflect.ValueOf(rpt.FileHeader), "bf", &names, values)
+	collectStructFields(reflect.ValueOf(rpt.InfoHeader).Elem(), "", &names, values)
+	return names, values
+}
+
+// Guess the version ID (as used by detectVersion) of a file, from the size
+// of its infoheader.
+func reportVersionID(rpt *bmpinspect.Report) string {
+	size := rpt.InfoHeader.HeaderSize()
+	switch {
+	case size == 12:
+		// Could also be os2v1; there's not enough information to tell.
+		return "winv2"
+	case size == 40:
+		return "winv3"
+	case size == 52:
+		return "52"
+	case size == 56:
+		return "56"
+	case size >= 16 && size <= 64:
+		return "os2v2"
+	case size == 108:
+		return "winv4"
+	case size == 124:
+		return "winv5"
+	}
+	return "unknown"
+}
+
+// Compare the header fields of two files. Only fields that both files have
+// are compared.
+func compareHeaders(a, b *bmpinspect.Report) []fieldChange {
+	var changes []fieldChange
+
+	if verA, verB := reportVersionID(a), reportVersionID(b); verA != verB {
+		changes = append(changes, fieldChange{"Version", verA, verB})
+	}
+
+	namesA, valuesA := headerFields(a)
+	_, valuesB := headerFields(b)
+	for _, name := range namesA {
+		after, ok := valuesB[name]
+		if ok && after != valuesA[name] {
+			changes = append(changes, fieldChange{name, valuesA[name], after})
+		}
+	}
+	return changes
+}
+
+// Print the header fields that differ between the files named baseline and
+// modified.
+func printHeaderChanges(ctx *ctx_type, baseline, modified string) error {
+	var rpts [2]*bmpinspect.Report
+	for i, fileName := range [2]string{baseline, modified} {
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		rpts[i], err = bmpinspect.InspectBytes(data, bmpinspect.Options{})
+		if err != nil {
+			return fmt.Errorf("%s: %v", fileName, err)
+		}
+	}
+
+	changes := compareHeaders(rpts[0], rpts[1])
+	for _, c := range changes {
+		if c.name == "Version" {
+			ctx.printf("Version: %s → %s (header structure changed)\n", c.before, c.after)
+			continue
+		}
+		ctx.printf("%s: %s → %s\n", c.name, c.before, c.after)
+	}
+	if len(changes) == 0 {
+		ctx.print("(No differences)\n")
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/complexity.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Measures of how complex an image is, which can help to tell what kind of
+// image it is (photographic, synthetic, scanned text, etc.).
+type complexity_type struct {
+	variance        float64 // The mean of the squared deviations from the mean
+	distinctiveness float64 // Unique pixel values / number of pixels
+	runsPerRow      float64 // The average number of runs of same-value pixels
+	edgeDensity     float64 // Horizontal transitions / adjacent pairs
+}
+
+// Compute the complexity of an uncompressed image. d is the bitmap bits.
+// Images with a palette are measured by their palette indices. Other images
+// are measured by their RGB colors, and the variance is that of all the R,
+// G, and B samples taken together.
+func computeComplexity(ctx *ctx_type, d []byte) complexity_type {
+	var c complexity_type
+	isIndexed := ctx.bitCount <= 8
+	masks := getEffectiveMasks(ctx)
+	numPixels := float64(ctx.imgWidth) * float64(ctx.imgHeight)
+
+	values := make(map[uint32]bool)
+	var sum, sumSq float64
+	var numRuns, numTransitions int64
+
+	for y := 0; y < ctx.imgHeight; y++ {
+		row := d[int64(y)*ctx.rowStride : int64(y+1)*ctx.rowStride]
+		var prev uint32
+		for x := 0; x < ctx.imgWidth; x++ {
+			var v uint32
+			if isIndexed {
+				v = getPixelValue(row, x, ctx.bitCount)
+				sum += float64(v)
+				sumSq += float64(v) * float64(v)
+			} else {
+				r, g, b := getPixelRGB(ctx, masks, getPixelValue(row, x, ctx.bitCount))
+				v = uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+				for _, s := range [3]float64{float64(r), float64(g), float64(b)} {
+					sum += s / 3
+					sumSq += s * s / 3
+				}
+			}
+			values[v] = true
+
+			if x == 0 {
+				numRuns++
+			} else if v != prev {
+				numRuns++
+				numTransitions++
+			}
+			prev = v
+		}
+	}
+
+	mean := sum / numPixels
+	c.variance = sumSq/numPixels - mean*mean
+	if c.variance < 0 {
+		c.variance = 0
+	}
+	c.distinctiveness = float64(len(values)) / numPixels
+	c.runsPerRow = float64(numRuns) / float64(ctx.imgHeight)
+	numPairs := float64(ctx.imgWidth-1) * float64(ctx.imgHeight)
+	if numPairs > 0 {
+		c.edgeDensity = float64(numTransitions) / numPairs
+	}
+	return c
+}
+
+func reportComplexity(ctx *ctx_type, d []byte) {
+	if ctx.imgWidth < 1 || ctx.imgHeight < 1 || printRowFuncs[ctx.bitCount] == nil {
+		return
+	}
+	c := computeComplexity(ctx, d)
+
+	startLine(ctx, 0)
+	ctx.printf("(Pixel variance: %.1f)\n", c.variance)
+	startLine(ctx, 0)
+	ctx.printf("(Distinctiveness: %.1f%%)\n", 100*c.distinctiveness)
+	startLine(ctx, 0)
+	ctx.printf("(Hypothetical RLE runs/row: %.1f avg)\n", c.runsPerRow)
+	startLine(ctx, 0)
+	ctx.printf("(Edge density: %.1f%%)\n", 100*c.edgeDensity)
+}
@@ -0,0 +1,66 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/conventions.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Conventional palettes, used by the -check-conventions option.
+
+type paletteEntry_type struct {
+	index   int
+	r, g, b uint8
+}
+
+// For 1-bit images, index 0 is usually black, and index 1 white.
+var conventionalPalette1 = []paletteEntry_type{
+	{0, 0x00, 0x00, 0x00}, {1, 0xff, 0xff, 0xff},
+}
+
+// The standard Windows 16-color (VGA) palette.
+var conventionalPalette4 = []paletteEntry_type{
+	{0, 0x00, 0x00, 0x00}, {1, 0x80, 0x00, 0x00}, {2, 0x00, 0x80, 0x00}, {3, 0x80, 0x80, 0x00},
+	{4, 0x00, 0x00, 0x80}, {5, 0x80, 0x00, 0x80}, {6, 0x00, 0x80, 0x80}, {7, 0xc0, 0xc0, 0xc0},
+	{8, 0x80, 0x80, 0x80}, {9, 0xff, 0x00, 0x00}, {10, 0x00, 0xff, 0x00}, {11, 0xff, 0xff, 0x00},
+	{12, 0x00, 0x00, 0xff}, {13, 0xff, 0x00, 0xff}, {14, 0x00, 0xff, 0xff}, {15, 0xff, 0xff, 0xff},
+}
+
+// The 20 static colors of the Windows 256-color system palette: the first
+// 10 and the last 10 entries. The other entries can be anything.
+var conventionalPalette8 = []paletteEntry_type{
+	{0, 0x00, 0x00, 0x00}, {1, 0x80, 0x00, 0x00}, {2, 0x00, 0x80, 0x00}, {3, 0x80, 0x80, 0x00},
+	{4, 0x00, 0x00, 0x80}, {5, 0x80, 0x00, 0x80}, {6, 0x00, 0x80, 0x80}, {7, 0xc0, 0xc0, 0xc0},
+	{8, 0xc0, 0xdc, 0xc0}, {9, 0xa6, 0xca, 0xf0},
+	{246, 0xff, 0xfb, 0xf0}, {247, 0xa0, 0xa0, 0xa4}, {248, 0x80, 0x80, 0x80}, {249, 0xff, 0x00, 0x00},
+	{250, 0x00, 0xff, 0x00}, {251, 0xff, 0xff, 0x00}, {252, 0x00, 0x00, 0xff}, {253, 0xff, 0x00, 0xff},
+	{254, 0x00, 0xff, 0xff}, {255, 0xff, 0xff, 0xff},
+}
+
+// Compare the color table d to the conventional palette for the image's
+// bit depth, and report the first entry that's different.
+func checkPaletteConventions(ctx *ctx_type, d []byte) {
+	var conv []paletteEntry_type
+
+	switch {
+	case ctx.bitCount == 1 && ctx.palNumEntries >= 2:
+		conv = conventionalPalette1
+	case ctx.bitCount == 4 && ctx.palNumEntries == 16:
+		conv = conventionalPalette4
+	case ctx.bitCount == 8 && ctx.palNumEntries == 256:
+		conv = conventionalPalette8
+	default:
+		return
+	}
+
+	for _, e := range conv {
+		pos := int64(e.index * ctx.palBytesPerEntry)
+		b, g, r := d[pos], d[pos+1], d[pos+2]
+		if r != e.r || g != e.g || b != e.b {
+			ctx.printf("Note: Palette deviates from Windows convention at index %d: "+
+				"got R=%d G=%d B=%d, expected R=%d G=%d B=%d\n",
+				e.index, r, g, b, e.r, e.g, e.b)
+			return
+		}
+	}
+	startLine(ctx, 0)
+	ctx.print("(Palette follows the Windows convention)\n")
+}
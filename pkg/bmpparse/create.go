@@ -0,0 +1,73 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/create.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+import "fmt"
+import "io/ioutil"
+import "encoding/binary"
+
+// Parse a -create-bmp dimensions string, of the form "WxHxBPP".
+func parseCreateDimensions(s string) (width, height, bitCount int, err error) {
+	_, err = fmt.Sscanf(s, "%dx%dx%d", &width, &height, &bitCount)
+	if err != nil {
+		return 0, 0, 0, errors.New("Bad dimensions (expected WxHxBPP)")
+	}
+	return width, height, bitCount, nil
+}
+
+// Write a minimal, valid, uncompressed BMP file with a BITMAPINFOHEADER. All
+// pixels are set to 0 (black). Images of 8 bits/pixel or less get a grayscale
+// palette.
+func createBMP(width, height, bitCount int, outputPath string) error {
+	var palNumEntries int
+
+	switch bitCount {
+	case 1, 4, 8:
+		palNumEntries = 1 << uint(bitCount)
+	case 16, 24, 32:
+	default:
+		return errors.New("Unsupported BitCount")
+	}
+	if width < 1 || height < 1 || width > 100000 || height > 100000 {
+		return errors.New("Bad image dimensions")
+	}
+
+	rowStride := ((width*bitCount + 31) / 32) * 4
+	pixelDataSize := rowStride * height
+	bfOffBits := 14 + 40 + 4*palNumEntries
+	fileSize := bfOffBits + pixelDataSize
+
+	d := make([]byte, fileSize)
+
+	// FILEHEADER
+	d[0] = 'B'
+	d[1] = 'M'
+	binary.LittleEndian.PutUint32(d[2:6], uint32(fileSize))
+	binary.LittleEndian.PutUint32(d[10:14], uint32(bfOffBits))
+
+	// BITMAPINFOHEADER
+	ih := d[14:54]
+	binary.LittleEndian.PutUint32(ih[0:4], 40)
+	binary.LittleEndian.PutUint32(ih[4:8], uint32(width))
+	binary.LittleEndian.PutUint32(ih[8:12], uint32(height))
+	binary.LittleEndian.PutUint16(ih[12:14], 1)
+	binary.LittleEndian.PutUint16(ih[14:16], uint16(bitCount))
+	binary.LittleEndian.PutUint32(ih[16:20], bI_RGB)
+	binary.LittleEndian.PutUint32(ih[20:24], uint32(pixelDataSize))
+	binary.LittleEndian.PutUint32(ih[32:36], uint32(palNumEntries))
+
+	// Color table (B-G-R-x)
+	for i := 0; i < palNumEntries; i++ {
+		v := byte(i * 255 / (palNumEntries - 1))
+		d[54+i*4] = v
+		d[54+i*4+1] = v
+		d[54+i*4+2] = v
+	}
+
+	// The pixel data is already all zeroes.
+
+	return ioutil.WriteFile(outputPath, d, 0644)
+}
@@ -0,0 +1,43 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/cstruct.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "fmt"
+import "regexp"
+import "strings"
+
+var cIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Return the whole file as C source code, for -generate-c-struct: a
+// uint8_t array named varName, and macros giving the image's dimensions.
+// readBmp must have been called.
+func generateCStruct(ctx *ctx_type, varName string) string {
+	const bytesPerLine = 16
+	var s strings.Builder
+
+	fmt.Fprintf(&s, "#define %s_WIDTH %d\n", varName, ctx.imgWidth)
+	fmt.Fprintf(&s, "#define %s_HEIGHT %d\n", varName, ctx.imgHeight)
+	switch ctx.compressionType {
+	case "rle4", "rle8", "rle24":
+		fmt.Fprintf(&s, "#define %s_COMPRESSED\n", varName)
+	}
+	s.WriteString("\n")
+
+	fmt.Fprintf(&s, "const uint8_t %s[] = {\n", varName)
+	data := ctx.file.bytes(0, ctx.file.size)
+	for i, b := range data {
+		if i%bytesPerLine == 0 {
+			s.WriteString("\t")
+		} else {
+			s.WriteString(" ")
+		}
+		fmt.Fprintf(&s, "0x%02X,", b)
+		if i%bytesPerLine == bytesPerLine-1 || i == len(data)-1 {
+			s.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&s, "}; /* %d bytes */\n", len(data))
+	return s.String()
+}
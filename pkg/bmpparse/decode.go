@@ -0,0 +1,116 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/decode.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+
+// Functions for decoding uncompressed pixels to RGB, for subcommands and
+// options that need the actual colors.
+
+// Read the pixel value at column x of a row of uncompressed pixels.
+func getPixelValue(row []byte, x int, bitCount int) uint32 {
+	switch bitCount {
+	case 1:
+		return uint32(row[x/8]>>uint(7-x%8)) & 0x01
+	case 2:
+		return uint32(row[x/4]>>uint(6-2*(x%4))) & 0x03
+	case 4:
+		return uint32(row[x/2]>>uint(4-4*(x%2))) & 0x0f
+	case 8:
+		return uint32(row[x])
+	case 16:
+		return uint32(getWORD(row[x*2 : x*2+2]))
+	case 24:
+		return uint32(row[x*3]) | uint32(row[x*3+1])<<8 | uint32(row[x*3+2])<<16
+	case 32:
+		return getDWORD(row[x*4 : x*4+4])
+	}
+	return 0
+}
+
+// Extract the channel selected by mask from v, scaled to the range 0-255,
+// without rounding. Used for statistics, where the rounding error of
+// getMaskedChannel would add up.
+func getMaskedChannelFloat(v uint32, mask uint32) float64 {
+	if mask == 0 {
+		return 0
+	}
+	shift := uint(0)
+	for mask&(1<<shift) == 0 {
+		shift++
+	}
+	maxVal := mask >> shift
+	return float64((v&mask)>>shift) * 255.0 / float64(maxVal)
+}
+
+// Extract the channel selected by mask from v, scaled to the range 0-255.
+func getMaskedChannel(v uint32, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	shift := uint(0)
+	for mask&(1<<shift) == 0 {
+		shift++
+	}
+	maxVal := uint64(mask >> shift)
+	return uint8((uint64((v&mask)>>shift)*255 + maxVal/2) / maxVal)
+}
+
+// Return the R, G, B, and A masks that apply to 16- and 32-bit pixels.
+func getEffectiveMasks(ctx *ctx_type) [4]uint32 {
+	if ctx.compressionCode == bI_BITFIELDS || ctx.compressionCode == bI_ALPHABITFIELDS {
+		return ctx.masks
+	}
+	if ctx.bitCount == 16 {
+		return [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+	}
+	return [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+}
+
+// Convert pixel value v to RGB. masks is from getEffectiveMasks.
+func getPixelRGB(ctx *ctx_type, masks [4]uint32, v uint32) (uint8, uint8, uint8) {
+	switch {
+	case ctx.bitCount <= 8:
+		if int(v) >= ctx.palNumEntries {
+			// Bad palette index
+			return 0, 0, 0
+		}
+		pos := ctx.palPos + int64(v)*int64(ctx.palBytesPerEntry)
+		e := ctx.file.bytes(pos, pos+3)
+		return e[2], e[1], e[0]
+	case ctx.bitCount == 24:
+		return uint8(v >> 16), uint8(v >> 8), uint8(v)
+	}
+	return getMaskedChannel(v, masks[0]), getMaskedChannel(v, masks[1]),
+		getMaskedChannel(v, masks[2])
+}
+
+// Decode the image to 8-bit RGB samples, with the top row first. The image
+// must have already been read by readBmp.
+func decodeImageRGB(ctx *ctx_type) ([]byte, error) {
+	if ctx.isCompressed || printRowFuncs[ctx.bitCount] == nil {
+		return nil, errors.New("Only uncompressed images can be decoded")
+	}
+	if ctx.rowStride < 1 || ctx.imgWidth < 1 || ctx.imgHeight < 1 ||
+		int64(ctx.bfOffBits)+ctx.calculatedSize > ctx.fileSize {
+		return nil, errors.New("Can't decode the image")
+	}
+
+	masks := getEffectiveMasks(ctx)
+	pix := make([]byte, 0, ctx.imgWidth*ctx.imgHeight*3)
+	for rowLogical := 0; rowLogical < ctx.imgHeight; rowLogical++ {
+		rowPhysical := rowLogical
+		if !ctx.topDown {
+			rowPhysical = ctx.imgHeight - 1 - rowLogical
+		}
+		pos := int64(ctx.bfOffBits) + int64(rowPhysical)*ctx.rowStride
+		row := ctx.file.read(pos, pos+ctx.rowStride)
+		for x := 0; x < ctx.imgWidth; x++ {
+			r, g, b := getPixelRGB(ctx, masks, getPixelValue(row, x, ctx.bitCount))
+			pix = append(pix, r, g, b)
+		}
+	}
+	return pix, nil
+}
@@ -0,0 +1,72 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/describe.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "fmt"
+import "math"
+import "strings"
+
+// Return a name for the kind of pixels the image has, e.g. "8-bit paletted".
+func describePixelType(ctx *ctx_type) string {
+	switch ctx.compressionType {
+	case "rle4", "rle8", "rle24":
+		return fmt.Sprintf("%d-bit RLE-compressed", ctx.bitCount)
+	case "huffman1d":
+		return "1-bit Huffman-compressed"
+	case "jpeg":
+		return "JPEG-compressed"
+	case "png":
+		return "PNG-compressed"
+	}
+
+	switch {
+	case ctx.bitCount <= 8:
+		return fmt.Sprintf("%d-bit paletted", ctx.bitCount)
+	case ctx.bitCount == 16:
+		return "16-bit high-color"
+	}
+	return fmt.Sprintf("%d-bit true-color", ctx.bitCount)
+}
+
+// Return a one-sentence description of the image, for -describe.
+func describeImage(ctx *ctx_type) string {
+	var s strings.Builder
+
+	orientation := "bottom-up"
+	if ctx.topDown {
+		orientation = "top-down"
+	}
+	verName := ctx.bmpVerName
+	if verName == "" {
+		verName = "BMP"
+	}
+	numPixels := int64(ctx.imgWidth) * int64(ctx.imgHeight)
+	fmt.Fprintf(&s, "A %s, %d×%d, %s %s image (%d pixels, %s", orientation,
+		ctx.imgWidth, ctx.imgHeight, describePixelType(ctx), verName,
+		numPixels, humanBytes(ctx.fileSize))
+
+	if ctx.isCompressed && ctx.actualBitsSize > 0 && ctx.calculatedSize > 0 {
+		fmt.Fprintf(&s, ", compressed to %s, %.0f%% of uncompressed size",
+			humanBytes(ctx.actualBitsSize),
+			100*float64(ctx.actualBitsSize)/float64(ctx.calculatedSize))
+	}
+	s.WriteString(")")
+
+	if ctx.palNumEntries > 0 {
+		fmt.Fprintf(&s, " with a %d-entry RGB palette", ctx.palNumEntries)
+	}
+	if ctx.xPelsPerMeter > 0 && ctx.yPelsPerMeter > 0 {
+		xDPI := math.Round(float64(ctx.xPelsPerMeter) * 0.0254)
+		yDPI := math.Round(float64(ctx.yPelsPerMeter) * 0.0254)
+		if xDPI == yDPI {
+			fmt.Fprintf(&s, ", created at %.0f DPI", xDPI)
+		} else {
+			fmt.Fprintf(&s, ", created at %.0f×%.0f DPI", xDPI, yDPI)
+		}
+	}
+
+	s.WriteString(".")
+	return s.String()
+}
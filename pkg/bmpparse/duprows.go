@@ -0,0 +1,81 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/duprows.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+
+// A run of consecutive rows (in file order) that are identical.
+type dupRowRun_type struct {
+	firstPhysRow int // The first row of the run, in file order
+	numRows      int
+}
+
+type dupRowInfo struct {
+	numDuplicates int // Rows that are identical to the previous row
+	runs          []dupRowRun_type
+	longest       dupRowRun_type
+}
+
+// Find the rows of an uncompressed image that are identical to the previous
+// row in the file, including the padding bytes. d is the bitmap bits.
+func detectDuplicateRows(ctx *ctx_type, d []byte) dupRowInfo {
+	var info dupRowInfo
+	var run dupRowRun_type
+
+	endRun := func() {
+		if run.numRows > 1 {
+			info.runs = append(info.runs, run)
+			if run.numRows > info.longest.numRows {
+				info.longest = run
+			}
+		}
+	}
+
+	for j := 0; j < ctx.imgHeight; j++ {
+		rowOffset := int64(j) * ctx.rowStride
+		row := d[rowOffset : rowOffset+ctx.rowStride]
+		if j > 0 && bytes.Equal(row, d[rowOffset-ctx.rowStride:rowOffset]) {
+			info.numDuplicates++
+			run.numRows++
+			continue
+		}
+		endRun()
+		run = dupRowRun_type{firstPhysRow: j, numRows: 1}
+	}
+	endRun()
+	return info
+}
+
+// Return the logical row numbers (0 = top) of the first and last rows of
+// run, with the smaller number first.
+func dupRowRunRange(ctx *ctx_type, run dupRowRun_type) (int, int) {
+	lastPhysRow := run.firstPhysRow + run.numRows - 1
+	if ctx.topDown {
+		return run.firstPhysRow, lastPhysRow
+	}
+	return ctx.imgHeight - 1 - lastPhysRow, ctx.imgHeight - 1 - run.firstPhysRow
+}
+
+func reportDuplicateRows(ctx *ctx_type, d []byte) {
+	if ctx.imgHeight < 1 || ctx.rowStride < 1 {
+		return
+	}
+	info := detectDuplicateRows(ctx, d)
+
+	startLine(ctx, 0)
+	ctx.printf("(Duplicate consecutive rows: %v of %v", info.numDuplicates, ctx.imgHeight)
+	if info.longest.numRows > 1 {
+		first, _ := dupRowRunRange(ctx, info.longest)
+		ctx.printf(", longest run: %v rows starting at row %v", info.longest.numRows, first)
+	}
+	ctx.print(")\n")
+
+	for _, run := range info.runs {
+		first, last := dupRowRunRange(ctx, run)
+		startLine(ctx, int64(run.firstPhysRow)*ctx.rowStride)
+		ctx.printf("(Rows %v–%v are identical (%v bytes redundant))\n", first, last,
+			int64(run.numRows-1)*ctx.rowStride)
+	}
+}
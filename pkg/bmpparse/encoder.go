@@ -0,0 +1,97 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/encoder.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "strings"
+
+// The -check-encoder option guesses which application wrote the file, from
+// quirks that some encoders are known to leave in the headers. These are
+// only heuristics: a file can match more than one, and many files match
+// none.
+
+type encoderSignature struct {
+	name  string
+	check func(ctx *ctx_type) bool
+}
+
+// The checks are only meaningful for a file with a fileheader, so the
+// infoheader is at offset 14.
+const encoderInfoheaderPos = 14
+
+// Report whether the file has a V4 or V5 header with CSType LCS_sRGB.
+func encoderHasSRGBHeader(ctx *ctx_type) bool {
+	if ctx.bmpVerID != "winv4" && ctx.bmpVerID != "winv5" {
+		return false
+	}
+	pos := int64(encoderInfoheaderPos + 56)
+	return ctx.file.size >= pos+4 && getDWORD(ctx.file.bytes(pos, pos+4)) == lCS_sRGB
+}
+
+// Report whether the CIEXYZTRIPLE endpoints of a V4 or V5 header are all 0.
+func encoderEndpointsAreZero(ctx *ctx_type) bool {
+	pos := int64(encoderInfoheaderPos + 60)
+	if ctx.file.size < pos+36 {
+		return true
+	}
+	for _, b := range ctx.file.bytes(pos, pos+36) {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+var encoderSignatures = []encoderSignature{
+	{"Adobe Photoshop", func(ctx *ctx_type) bool {
+		// Photoshop can put an ICC profile in the gap before the bits, and
+		// adds 2 bytes to the end of uncompressed bits, which it includes
+		// in SizeImage.
+		return ctx.hasNonStandardProfile ||
+			(!ctx.isCompressed && ctx.calculatedSize > 0 &&
+				int64(ctx.sizeImage) == ctx.calculatedSize+2)
+	}},
+	{"ImageMagick", func(ctx *ctx_type) bool {
+		// ImageMagick fills in the endpoints even for sRGB, where they are
+		// supposed to be ignored.
+		return encoderHasSRGBHeader(ctx) && !encoderEndpointsAreZero(ctx)
+	}},
+	{"Windows icon/cursor conversion", func(ctx *ctx_type) bool {
+		// Images converted from cursors can have the hotspot in the
+		// bfReserved fields.
+		if ctx.file.size < 10 {
+			return false
+		}
+		x := int(getWORD(ctx.file.bytes(6, 8)))
+		y := int(getWORD(ctx.file.bytes(8, 10)))
+		return (x != 0 || y != 0) && x < ctx.imgWidth && y < ctx.imgHeight
+	}},
+}
+
+// Return the names of the encoders whose signatures match.
+func identifyEncoders(ctx *ctx_type) []string {
+	var names []string
+	if ctx.noFileheader {
+		return nil
+	}
+	for _, sig := range encoderSignatures {
+		if sig.check(ctx) {
+			names = append(names, sig.name)
+		}
+	}
+	return names
+}
+
+func printLikelyEncoder(ctx *ctx_type) {
+	names := identifyEncoders(ctx)
+	startLineAbsolute(ctx, ctx.fileSize)
+	switch len(names) {
+	case 0:
+		ctx.print("(Encoder not identified)\n")
+	case 1:
+		ctx.printf("(Likely encoder: %s)\n", names[0])
+	default:
+		ctx.printf("(Possible encoders: %s)\n", strings.Join(names, ", "))
+	}
+}
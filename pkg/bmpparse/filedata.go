@@ -0,0 +1,114 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/filedata.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "io"
+import "io/ioutil"
+
+// Small reads are rounded up to this many bytes, and the result is kept for
+// the reads that follow.
+const fileDataBlockSize = 64 * 1024
+
+// The BMP file, read on demand. Only the block read most recently is kept
+// in memory, so the headers (which are parsed with many small reads) come
+// from one read, and the bitmap bits are never all in memory at once unless
+// an option needs them to be.
+type fileData_type struct {
+	r    io.ReadSeeker
+	size int64
+	// If not nil, closed by close.
+	closer io.Closer
+
+	block    []byte
+	blockPos int64
+
+	// The first read error, returned by readBmp.
+	err error
+}
+
+// Prepare to read the file from r. The file size is found by seeking to
+// the end. If r can't seek (a pipe, for example), it is read into memory.
+func newFileData(r io.ReadSeeker) (*fileData_type, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		d, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(d)
+		size = int64(len(d))
+	}
+	return &fileData_type{r: r, size: size}, nil
+}
+
+// Return the bytes from file offset pos to end, which must be in the file.
+// The caller must not modify them.
+func (f *fileData_type) bytes(pos, end int64) []byte {
+	if pos >= f.blockPos && end <= f.blockPos+int64(len(f.block)) {
+		return f.block[pos-f.blockPos : end-f.blockPos]
+	}
+	if end-pos >= fileDataBlockSize {
+		return f.read(pos, end)
+	}
+	blockEnd := pos + fileDataBlockSize
+	if blockEnd > f.size {
+		blockEnd = f.size
+	}
+	if blockEnd < end {
+		blockEnd = end
+	}
+	// A new slice is read, so slices of the old block stay valid.
+	f.block = f.read(pos, blockEnd)
+	f.blockPos = pos
+	return f.block[:end-pos]
+}
+
+// Read the bytes from pos to end, without keeping them. Used for the pixel
+// rows, so that reading them doesn't push the headers and palette out of
+// the block. If the read fails, the error is remembered, and zeros are
+// returned.
+func (f *fileData_type) read(pos, end int64) []byte {
+	d := make([]byte, end-pos)
+	if f.err != nil {
+		return d
+	}
+	n, err := f.ReadAt(d, pos)
+	if err != nil && n < len(d) {
+		f.err = err
+	}
+	return d
+}
+
+// ReadAt reads len(p) bytes at file offset off, so that a part of the file
+// can be read sequentially with io.NewSectionReader. Errors other than
+// reaching the end of the file are remembered.
+func (f *fileData_type) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	var err error
+
+	if ra, ok := f.r.(io.ReaderAt); ok {
+		n, err = ra.ReadAt(p, off)
+	} else if _, err = f.r.Seek(off, io.SeekStart); err == nil {
+		n, err = io.ReadFull(f.r, p)
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF && f.err == nil {
+		f.err = err
+	}
+	return n, err
+}
+
+// Close the file, if it was opened by loadBmpFile.
+func (f *fileData_type) close() error {
+	if f.closer == nil {
+		return nil
+	}
+	return f.closer.Close()
+}
+
+// Return a reader for the part of the file from pos to end.
+func (f *fileData_type) section(pos, end int64) io.Reader {
+	return io.NewSectionReader(f, pos, end-pos)
+}
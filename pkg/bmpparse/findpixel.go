@@ -0,0 +1,103 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/findpixel.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "encoding/hex"
+import "errors"
+
+// The number of locations printed by -find-pixel.
+const maxFindPixelLocations = 20
+
+// Parse the RRGGBB argument of -find-pixel.
+func parseFindPixelColor(s string) ([3]uint8, error) {
+	var clr [3]uint8
+	d, err := hex.DecodeString(s)
+	if err != nil || len(d) != 3 {
+		return clr, errors.New("-find-pixel color must be of the form RRGGBB")
+	}
+	copy(clr[:], d)
+	return clr, nil
+}
+
+// Find the pixels of an uncompressed image whose color is target. Returns
+// the number found, and the (x,y) locations (y = logical row number) of up
+// to maxFindPixelLocations of them, in file order. For indexed images, the
+// color is looked up in the palette, and for BITFIELDS images, it is the
+// color decoded from the masks.
+func findPixelColor(ctx *ctx_type, d []byte, target [3]uint8) (count int, locations [][2]int) {
+	masks := getEffectiveMasks(ctx)
+	for j := 0; j < ctx.imgHeight; j++ {
+		row := d[int64(j)*ctx.rowStride : int64(j+1)*ctx.rowStride]
+		y := j
+		if !ctx.topDown {
+			y = ctx.imgHeight - 1 - j
+		}
+		for x := 0; x < ctx.imgWidth; x++ {
+			r, g, b := getPixelRGB(ctx, masks, getPixelValue(row, x, ctx.bitCount))
+			if [3]uint8{r, g, b} != target {
+				continue
+			}
+			count++
+			if len(locations) < maxFindPixelLocations {
+				locations = append(locations, [2]int{x, y})
+			}
+		}
+	}
+	return count, locations
+}
+
+// Print the palette entry that is the same as, or closest to, target.
+func printNearestPaletteEntry(ctx *ctx_type, target [3]uint8) {
+	pal := getPaletteRGB(ctx)
+	if len(pal) == 0 {
+		return
+	}
+	best := 0
+	bestDist := -1
+	for i, c := range pal {
+		dist := colorDistanceSq(int(c[0]), int(c[1]), int(c[2]),
+			int(target[0]), int(target[1]), int(target[2]))
+		if bestDist < 0 || dist < bestDist {
+			best = i
+			bestDist = dist
+		}
+	}
+
+	c := pal[best]
+	startLineAbsolute(ctx, ctx.palPos+int64(best*ctx.palBytesPerEntry))
+	ctx.printf("(Palette index %v = #%02X%02X%02X", best, c[0], c[1], c[2])
+	if bestDist == 0 {
+		ctx.print(" (exact match))\n")
+	} else {
+		ctx.print(" (closest match))\n")
+	}
+}
+
+func reportFindPixel(ctx *ctx_type, d []byte) {
+	target := ctx.findPixelColor
+	if ctx.imgHeight < 1 || ctx.rowStride < 1 {
+		return
+	}
+	if ctx.bitCount <= 8 {
+		printNearestPaletteEntry(ctx, target)
+	}
+
+	count, locations := findPixelColor(ctx, d, target)
+	startLine(ctx, 0)
+	ctx.printf("(Found %v pixels matching #%02X%02X%02X)\n", count,
+		target[0], target[1], target[2])
+	for _, loc := range locations {
+		physRow := loc[1]
+		if !ctx.topDown {
+			physRow = ctx.imgHeight - 1 - loc[1]
+		}
+		startLine(ctx, int64(physRow)*ctx.rowStride)
+		ctx.printf("(x=%v, y=%v)\n", loc[0], loc[1])
+	}
+	if count > len(locations) {
+		startLine(ctx, 0)
+		ctx.printf("(%v more occurrences not shown)\n", count-len(locations))
+	}
+}
@@ -0,0 +1,206 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/formatcheck.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+import "fmt"
+import "strings"
+
+// -format-check checks a file against a "profile": the subset of the BMP
+// format that some kind of software is expected to support.
+
+type validationError struct {
+	message string
+}
+
+func newValidationError(format string, a ...interface{}) *validationError {
+	return &validationError{message: fmt.Sprintf(format, a...)}
+}
+
+// A rule returns nil if the file follows it.
+type validationRule func(ctx *ctx_type) *validationError
+
+type validationProfile struct {
+	name  string
+	rules []validationRule
+}
+
+// The checks are only made on a file with a fileheader, so the infoheader is
+// at offset 14. Returns 0 if the field is not in the file.
+func profileInfoheaderDWORD(ctx *ctx_type, offset int) uint32 {
+	pos := int64(14 + offset)
+	if int(ctx.infoHeaderSize) < offset+4 || ctx.file.size < pos+4 {
+		return 0
+	}
+	return getDWORD(ctx.file.bytes(pos, pos+4))
+}
+
+func ruleNotOS2(ctx *ctx_type) *validationError {
+	if strings.HasPrefix(ctx.bmpVerID, "os2") {
+		return newValidationError("OS/2 header format (%s) is not allowed", ctx.bmpVerName)
+	}
+	return nil
+}
+
+func ruleOS2(ctx *ctx_type) *validationError {
+	if !strings.HasPrefix(ctx.bmpVerID, "os2") {
+		return newValidationError("Header format (%s) is not an OS/2 format", ctx.bmpVerName)
+	}
+	return nil
+}
+
+// Return a rule that allows only the given bit counts.
+func ruleBitCount(allowed ...int) validationRule {
+	return func(ctx *ctx_type) *validationError {
+		for _, n := range allowed {
+			if ctx.bitCount == n {
+				return nil
+			}
+		}
+		return newValidationError("BitCount %v is not allowed", ctx.bitCount)
+	}
+}
+
+// Return a rule that allows only the given compression codes.
+func ruleCompression(allowed ...uint32) validationRule {
+	return func(ctx *ctx_type) *validationError {
+		for _, c := range allowed {
+			if ctx.compressionCode == c {
+				return nil
+			}
+		}
+		return newValidationError("Compression %v is not allowed", ctx.compressionCode)
+	}
+}
+
+func ruleNoSrcPrerotate(ctx *ctx_type) *validationError {
+	if ctx.hasSrcPrerotate {
+		return newValidationError("BI_SRCPREROTATE is not allowed")
+	}
+	return nil
+}
+
+func ruleClrUsedSet(ctx *ctx_type) *validationError {
+	if ctx.bitCount <= 8 && ctx.infoHeaderSize >= 40 && profileInfoheaderDWORD(ctx, 32) == 0 {
+		return newValidationError("biClrUsed must be set for images with a palette")
+	}
+	return nil
+}
+
+func ruleReservedZero(ctx *ctx_type) *validationError {
+	if getWORD(ctx.file.bytes(6, 8)) != 0 || getWORD(ctx.file.bytes(8, 10)) != 0 {
+		return newValidationError("bfReserved1 and bfReserved2 must be 0")
+	}
+	return nil
+}
+
+func ruleFileSize(ctx *ctx_type) *validationError {
+	if int64(getDWORD(ctx.file.bytes(2, 6))) != ctx.fileSize {
+		return newValidationError("bfSize must equal the file size (%v)", ctx.fileSize)
+	}
+	return nil
+}
+
+func ruleSizeImageSet(ctx *ctx_type) *validationError {
+	if ctx.sizeImage == 0 && ctx.bmpVerID != "os2v1" && ctx.bmpVerID != "winv2" {
+		return newValidationError("biSizeImage must be set")
+	}
+	return nil
+}
+
+func ruleNoGap(ctx *ctx_type) *validationError {
+	for _, g := range ctx.gaps {
+		if g.start < int64(ctx.bfOffBits) {
+			return newValidationError("There must be no unused bytes before the bitmap bits")
+		}
+	}
+	return nil
+}
+
+func ruleNoWarnings(ctx *ctx_type) *validationError {
+	if len(ctx.warnings) > 0 {
+		return newValidationError("There must be no warnings (found %v)", len(ctx.warnings))
+	}
+	return nil
+}
+
+var gdiRules = []validationRule{
+	ruleNotOS2,
+	ruleBitCount(1, 4, 8, 16, 24, 32),
+	ruleCompression(bI_RGB, bI_RLE8, bI_RLE4, bI_BITFIELDS),
+	ruleNoSrcPrerotate,
+}
+
+var validationProfiles = []validationProfile{
+	{"windows-gdi", gdiRules},
+	{"gdi-dib", append(append([]validationRule{}, gdiRules...), ruleClrUsedSet)},
+	{"printer-driver", []validationRule{
+		ruleNotOS2,
+		ruleBitCount(0, 1, 4, 8, 16, 24, 32),
+		ruleCompression(bI_RGB, bI_RLE8, bI_RLE4, bI_BITFIELDS, bI_JPEG, bI_PNG),
+		ruleNoSrcPrerotate,
+	}},
+	{"windows-ce", []validationRule{
+		ruleNotOS2,
+		ruleBitCount(1, 2, 4, 8, 16, 24, 32),
+		ruleCompression(bI_RGB, bI_BITFIELDS, bI_ALPHABITFIELDS),
+	}},
+	{"os2", []validationRule{
+		ruleOS2,
+		ruleBitCount(1, 4, 8, 24),
+	}},
+	{"strict-spec", append(append([]validationRule{}, gdiRules...),
+		ruleClrUsedSet, ruleReservedZero, ruleFileSize, ruleSizeImageSet, ruleNoGap,
+		ruleNoWarnings)},
+}
+
+func validationProfileNames() string {
+	var names []string
+	for _, p := range validationProfiles {
+		names = append(names, p.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func findValidationProfile(name string) (validationProfile, error) {
+	for _, p := range validationProfiles {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return validationProfile{}, errors.New("Unknown format profile (valid profiles: " +
+		validationProfileNames() + ")")
+}
+
+// Check the file against the named profile, and print each rule it breaks.
+// readBmp must have been called.
+func validateProfile(ctx *ctx_type, profile string) error {
+	p, err := findValidationProfile(profile)
+	if err != nil {
+		return err
+	}
+
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.printf("----- Format check: %s -----\n", p.name)
+	if ctx.fileType != "BM" {
+		startLineAbsolute(ctx, ctx.fileSize)
+		ctx.print("(Only files with a \"BM\" fileheader can be checked)\n")
+		return nil
+	}
+	numViolations := 0
+	for _, rule := range p.rules {
+		if verr := rule(ctx); verr != nil {
+			ctx.printf("Violation: %s\n", verr.message)
+			numViolations++
+		}
+	}
+	startLineAbsolute(ctx, ctx.fileSize)
+	if numViolations == 0 {
+		ctx.printf("(The file conforms to the %s profile)\n", p.name)
+	} else {
+		ctx.printf("(%v violations of the %s profile)\n", numViolations, p.name)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/fourcc.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Some fields, such as CSType, hold a FOURCC: four ASCII characters, read
+// as a little-endian DWORD. The characters are usually chosen so that they
+// read correctly when the DWORD is written most significant byte first, so
+// LCS_sRGB (0x73524742, "sRGB") is stored in the file as "BGRs".
+
+// Return the bytes of value in file (little-endian) order.
+func fourccBytes(value uint32) []byte {
+	return []byte{byte(value), byte(value >> 8), byte(value >> 16), byte(value >> 24)}
+}
+
+func isFOURCCPrintable(value uint32) bool {
+	for _, b := range fourccBytes(value) {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Print a field value that may be a FOURCC, in hex, and, if all its bytes
+// are printable, as a string in both byte orders.
+func printFOURCC(ctx *ctx_type, value uint32) {
+	ctx.printf("0x%x", value)
+	if !isFOURCCPrintable(value) {
+		return
+	}
+	le := fourccBytes(value)
+	be := []byte{le[3], le[2], le[1], le[0]}
+	ctx.printf(" = %+q (little-endian) = %+q (big-endian)", string(le), string(be))
+}
@@ -0,0 +1,72 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/gaps.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// A block of bytes that is not part of any known section.
+type gapInfo_type struct {
+	start   int64
+	size    int64
+	content string
+}
+
+// The number of bytes of each gap that -show-gaps displays.
+const gapHexDumpSize = 64
+
+// Describe the contents of a gap. Unlike identifyBinaryContent, this always
+// returns something.
+func classifyGapContent(d []byte) string {
+	content := identifyBinaryContent(d)
+	if content != "" {
+		return content
+	}
+
+	var numText int
+	for _, c := range d {
+		if (c >= 32 && c <= 126) || c == '\t' || c == '\r' || c == '\n' {
+			numText++
+		}
+	}
+	if numText*10 >= len(d)*9 {
+		return "text"
+	}
+	return "random or unidentified data"
+}
+
+func printGapDetails(ctx *ctx_type, g gapInfo_type) {
+	startLineAbsolute(ctx, g.start)
+	ctx.printf("(Offsets %v-%v, %v bytes)\n", g.start, g.start+g.size-1, g.size)
+	startLineAbsolute(ctx, g.start)
+	ctx.printf("(Contents: %s)\n", g.content)
+
+	n := g.size
+	if n > gapHexDumpSize {
+		n = gapHexDumpSize
+	}
+	for i := int64(0); i < n; i += 16 {
+		startLineAbsolute(ctx, g.start+i)
+		for j := i; j < i+16 && j < n; j++ {
+			ctx.printf(" %02x", ctx.file.bytes(g.start+j, g.start+j+1)[0])
+		}
+		ctx.print("\n")
+	}
+	if n < g.size {
+		startLineAbsolute(ctx, g.start+n)
+		ctx.printf("(%v more bytes not shown)\n", g.size-n)
+	}
+}
+
+func printGapSummary(ctx *ctx_type) {
+	var total int64
+	for _, g := range ctx.gaps {
+		total += g.size
+	}
+	pct := 100.0 * float64(total) / float64(ctx.fileSize)
+
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.printf("(Total gap bytes: %v (%.1f%% of file))\n", total, pct)
+	if pct > 5.0 {
+		ctx.warn("header", "File has significant gap data (%.1f%% = %v bytes)", pct, total)
+	}
+}
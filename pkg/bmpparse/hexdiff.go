@@ -0,0 +1,169 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/hexdiff.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "fmt"
+import "strings"
+
+// The number of bytes from each file shown on a line by -hex-diff.
+const hexDiffBytesPerLine = 8
+
+// The maximum number of lines printed by -hex-diff, unless -no-limit is used.
+const hexDiffMaxLines = 4096
+
+// Runs of at least this many matching lines are replaced by a summary.
+const hexDiffMinMatchingRun = 3
+
+// One line of a hex diff. a or b may be shorter than hexDiffBytesPerLine,
+// or empty, at the end of a file.
+type hexDiffLine struct {
+	offset int64
+	a, b   []byte
+}
+
+func (ln *hexDiffLine) matches() bool {
+	return bytes.Equal(ln.a, ln.b)
+}
+
+// Report whether byte i of the line differs between the files.
+func (ln *hexDiffLine) differs(i int) bool {
+	if i >= len(ln.a) || i >= len(ln.b) {
+		return i < len(ln.a) || i < len(ln.b)
+	}
+	return ln.a[i] != ln.b[i]
+}
+
+func splitHexDiffLines(a, b []byte) []hexDiffLine {
+	var lines []hexDiffLine
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for offset := 0; offset < n; offset += hexDiffBytesPerLine {
+		lines = append(lines, hexDiffLine{offset: int64(offset),
+			a: hexDiffSlice(a, offset), b: hexDiffSlice(b, offset)})
+	}
+	return lines
+}
+
+// Return the bytes of d on the line that starts at offset.
+func hexDiffSlice(d []byte, offset int) []byte {
+	if offset >= len(d) {
+		return nil
+	}
+	end := offset + hexDiffBytesPerLine
+	if end > len(d) {
+		end = len(d)
+	}
+	return d[offset:end]
+}
+
+// Format one file's bytes on a line.
+func (ctx *ctx_type) formatHexDiffBytes(ln *hexDiffLine, d []byte) string {
+	var sb strings.Builder
+	for i := 0; i < hexDiffBytesPerLine; i++ {
+		if i >= len(d) {
+			sb.WriteString("   ")
+			continue
+		}
+		switch {
+		case !ctx.useColor:
+			fmt.Fprintf(&sb, "%02x ", d[i])
+		case ln.differs(i):
+			fmt.Fprintf(&sb, "%s%02x%s ", ansiRed, d[i], ansiReset)
+		default:
+			fmt.Fprintf(&sb, "%s%02x%s ", ansiGray, d[i], ansiReset)
+		}
+	}
+	return sb.String()
+}
+
+// Format the markers that are printed under the differing bytes, if ANSI
+// colors are not being used.
+func formatHexDiffMarkers(ln *hexDiffLine) string {
+	var sb strings.Builder
+	for i := 0; i < hexDiffBytesPerLine; i++ {
+		if ln.differs(i) {
+			sb.WriteString("^^ ")
+		} else {
+			sb.WriteString("   ")
+		}
+	}
+	return sb.String()
+}
+
+// Print the bytes of two files side by side, highlighting the bytes that
+// differ.
+func (ctx *ctx_type) printHexDiff(a, b []byte) {
+	lines := splitHexDiffLines(a, b)
+	numOutputLines := 0
+	var numDiffBytes int
+
+	// Print a line of output; returns false if the limit has been reached.
+	output := func(s string) bool {
+		if !ctx.noLimit && numOutputLines >= hexDiffMaxLines {
+			return false
+		}
+		ctx.print(s)
+		numOutputLines++
+		return true
+	}
+
+	output(fmt.Sprintf("%7s | %-24s| %-24s| %s\n", "OFFSET", "FILE1 BYTES", "FILE2 BYTES", "DIFF"))
+
+	for i := 0; i < len(lines); i++ {
+		// Look for a long run of matching lines.
+		j := i
+		for j < len(lines) && lines[j].matches() {
+			j++
+		}
+		if j-i >= hexDiffMinMatchingRun {
+			n := int64(0)
+			for k := i; k < j; k++ {
+				n += int64(len(lines[k].a))
+			}
+			if !output(fmt.Sprintf("%7d | ... (%d matching bytes) ...\n", lines[i].offset, n)) {
+				break
+			}
+			i = j - 1
+			continue
+		}
+
+		ln := &lines[i]
+		diffCount := 0
+		for k := 0; k < hexDiffBytesPerLine; k++ {
+			if ln.differs(k) {
+				diffCount++
+			}
+		}
+		numDiffBytes += diffCount
+
+		diffStr := ""
+		if diffCount > 0 {
+			diffStr = fmt.Sprintf("%d", diffCount)
+		}
+		if !output(fmt.Sprintf("%7d | %s| %s| %s\n", ln.offset, ctx.formatHexDiffBytes(ln, ln.a),
+			ctx.formatHexDiffBytes(ln, ln.b), diffStr)) {
+			break
+		}
+		if diffCount > 0 && !ctx.useColor {
+			markers := formatHexDiffMarkers(ln)
+			if !output(fmt.Sprintf("%7s | %s| %s|\n", "", markers, markers)) {
+				break
+			}
+		}
+	}
+
+	if !ctx.noLimit && numOutputLines >= hexDiffMaxLines {
+		ctx.printf("(Output limited to %d lines; use -no-limit to see everything)\n",
+			hexDiffMaxLines)
+		return
+	}
+	if len(a) != len(b) {
+		ctx.printf("(File sizes differ: %v vs. %v bytes)\n", len(a), len(b))
+	}
+	ctx.printf("(Bytes that differ: %v)\n", numDiffBytes)
+}
@@ -0,0 +1,145 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/huffman.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "fmt"
+import "strings"
+
+// OS/2 Huffman 1D compression is said to use the modified Huffman codes of
+// ITU-T T.4 (fax). We only know the terminating codes (runs of 0-63
+// pixels), which are enough to see whether the data looks right. Make-up
+// codes (runs of 64 or more) are reported as unrecognized.
+
+type huffmanCode_type struct {
+	code   uint32
+	length uint8
+}
+
+// The T.4 terminating codes, indexed by color (0=white, 1=black) and run
+// length.
+var huffman1DCodes = [2][64]huffmanCode_type{
+	{ // White
+		{0x35, 8}, {0x07, 6}, {0x07, 4}, {0x08, 4}, {0x0b, 4}, {0x0c, 4}, {0x0e, 4}, {0x0f, 4},
+		{0x13, 5}, {0x14, 5}, {0x07, 5}, {0x08, 5}, {0x08, 6}, {0x03, 6}, {0x34, 6}, {0x35, 6},
+		{0x2a, 6}, {0x2b, 6}, {0x27, 7}, {0x0c, 7}, {0x08, 7}, {0x17, 7}, {0x03, 7}, {0x04, 7},
+		{0x28, 7}, {0x2b, 7}, {0x13, 7}, {0x24, 7}, {0x18, 7}, {0x02, 8}, {0x03, 8}, {0x1a, 8},
+		{0x1b, 8}, {0x12, 8}, {0x13, 8}, {0x14, 8}, {0x15, 8}, {0x16, 8}, {0x17, 8}, {0x28, 8},
+		{0x29, 8}, {0x2a, 8}, {0x2b, 8}, {0x2c, 8}, {0x2d, 8}, {0x04, 8}, {0x05, 8}, {0x0a, 8},
+		{0x0b, 8}, {0x52, 8}, {0x53, 8}, {0x54, 8}, {0x55, 8}, {0x24, 8}, {0x25, 8}, {0x58, 8},
+		{0x59, 8}, {0x5a, 8}, {0x5b, 8}, {0x4a, 8}, {0x4b, 8}, {0x32, 8}, {0x33, 8}, {0x34, 8},
+	},
+	{ // Black
+		{0x37, 10}, {0x02, 3}, {0x03, 2}, {0x02, 2}, {0x03, 3}, {0x03, 4}, {0x02, 4}, {0x03, 5},
+		{0x05, 6}, {0x04, 6}, {0x04, 7}, {0x05, 7}, {0x07, 7}, {0x04, 8}, {0x07, 8}, {0x18, 9},
+		{0x17, 10}, {0x18, 10}, {0x08, 10}, {0x67, 11}, {0x68, 11}, {0x6c, 11}, {0x37, 11}, {0x28, 11},
+		{0x17, 11}, {0x18, 11}, {0xca, 12}, {0xcb, 12}, {0xcc, 12}, {0xcd, 12}, {0x68, 12}, {0x69, 12},
+		{0x6a, 12}, {0x6b, 12}, {0xd2, 12}, {0xd3, 12}, {0xd4, 12}, {0xd5, 12}, {0xd6, 12}, {0xd7, 12},
+		{0x6c, 12}, {0x6d, 12}, {0xda, 12}, {0xdb, 12}, {0x54, 12}, {0x55, 12}, {0x56, 12}, {0x57, 12},
+		{0x64, 12}, {0x65, 12}, {0x52, 12}, {0x53, 12}, {0x24, 12}, {0x37, 12}, {0x38, 12}, {0x27, 12},
+		{0x28, 12}, {0x58, 12}, {0x59, 12}, {0x2b, 12}, {0x2c, 12}, {0x5a, 12}, {0x66, 12}, {0x67, 12},
+	},
+}
+
+// The T.4 end-of-line code.
+var huffman1DEOL = huffmanCode_type{0x001, 12}
+
+const (
+	huffman1DMaxCodeLength = 13
+	huffman1DMaxCodes      = 100
+	huffman1DMaxBits       = 1000
+	huffman1DCodesPerLine  = 16
+)
+
+// Return the bit at bit position pos of d, most significant bit first.
+func getBitMSBFirst(d []byte, pos int) uint32 {
+	return uint32(d[pos/8]>>uint(7-pos%8)) & 1
+}
+
+// Find the run length for the code at bit position pos of d, for the given
+// color. Returns the run length (or -1 for EOL) and the code length, or a
+// code length of 0 if there is no valid code.
+func readHuffman1DCode(d []byte, pos int, color int) (int, int) {
+	var code uint32
+	for length := 1; length <= huffman1DMaxCodeLength && pos+length <= len(d)*8; length++ {
+		code = code<<1 | getBitMSBFirst(d, pos+length-1)
+		for run, c := range huffman1DCodes[color] {
+			if int(c.length) == length && c.code == code {
+				return run, length
+			}
+		}
+		if int(huffman1DEOL.length) == length && huffman1DEOL.code == code {
+			return -1, length
+		}
+	}
+	return 0, 0
+}
+
+// Return how many of the size bytes of compressed data printHuffman1DBytes
+// can look at: a code can start before huffman1DMaxBits, and be up to
+// huffman1DMaxCodeLength bits long.
+func huffman1DBytesNeeded(size int64) int64 {
+	n := int64(huffman1DMaxBits+huffman1DMaxCodeLength+7) / 8
+	if n > size {
+		n = size
+	}
+	return n
+}
+
+// Print the first bytes of Huffman 1D compressed data, and try to decode
+// them as T.4 codes. Runs are printed as [W12] (a run of 12 white pixels)
+// or [B3] (3 black pixels).
+func printHuffman1DBytes(ctx *ctx_type, d []byte) {
+	n := len(d)
+	if n > 16 {
+		n = 16
+	}
+	startLine(ctx, 0)
+	ctx.printf("(First %d bytes: % x)\n", n, d[:n])
+
+	var tokens []string
+	var tokenPos []int
+	pos := 0
+	color := 0 // Each row starts with a white run.
+	x := 0
+	for len(tokens) < huffman1DMaxCodes && pos < huffman1DMaxBits && pos < len(d)*8 {
+		run, length := readHuffman1DCode(d, pos, color)
+		tokenPos = append(tokenPos, pos)
+		if length == 0 {
+			// Show the bits we couldn't decode, and stop, since we've lost
+			// our place.
+			var sb strings.Builder
+			for i := pos; i < pos+huffman1DMaxCodeLength && i < len(d)*8; i++ {
+				sb.WriteByte('0' + byte(getBitMSBFirst(d, i)))
+			}
+			tokens = append(tokens, "[?"+sb.String()+"]")
+			break
+		}
+		pos += length
+
+		if run < 0 {
+			tokens = append(tokens, "[EOL]")
+			color = 0
+			x = 0
+			continue
+		}
+		tokens = append(tokens, fmt.Sprintf("[%c%d]", "WB"[color], run))
+		x += run
+		if x >= ctx.imgWidth {
+			color = 0
+			x = 0
+		} else {
+			color = 1 - color
+		}
+	}
+
+	for i := 0; i < len(tokens); i += huffman1DCodesPerLine {
+		end := i + huffman1DCodesPerLine
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		startLine(ctx, int64(tokenPos[i]/8))
+		ctx.printf("(Huffman 1D: %s)\n", strings.Join(tokens[i:end], " "))
+	}
+}
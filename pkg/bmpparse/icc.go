@@ -0,0 +1,225 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/icc.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "fmt"
+import "strings"
+import "encoding/binary"
+
+var iccDeviceClassNames = map[string]string{
+	"scnr": "Scanner",
+	"mntr": "Monitor",
+	"prtr": "Printer",
+	"link": "DeviceLink",
+	"spac": "ColorSpace",
+	"abst": "Abstract",
+	"nmcl": "NamedColor",
+}
+
+var iccColorSpaceNames = map[string]string{
+	"RGB ": "RGB",
+	"CMYK": "CMYK",
+	"Lab ": "CIELAB",
+	"XYZ ": "CIEXYZ",
+}
+
+// Look up a 4-byte ICC signature in a table of names. If it's not found,
+// return the signature itself, without trailing spaces.
+func iccSignatureName(names map[string]string, sig []byte) string {
+	name, ok := names[string(sig)]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("%+q", strings.TrimRight(string(sig), " "))
+}
+
+// Return a one-line summary of an ICC profile, based on its header.
+// Note that ICC profiles use big-endian byte order.
+func iccProfileSummary(d []byte) string {
+	if len(d) < 36 {
+		return fmt.Sprintf("Not an ICC profile (too small), %v bytes", len(d))
+	}
+	major := d[8]
+	minor := d[9] >> 4
+	patch := d[9] & 0x0f
+	size := binary.BigEndian.Uint32(d[0:4])
+	s := fmt.Sprintf("ICC profile v%d.%d.%d, %s, %s, %v bytes", major, minor, patch,
+		iccSignatureName(iccDeviceClassNames, d[12:16]),
+		iccSignatureName(iccColorSpaceNames, d[16:20]), size)
+	if int64(size) != int64(len(d)) {
+		s += fmt.Sprintf(" (%v bytes available)", len(d))
+	}
+	return s
+}
+
+var iccPlatformNames = map[string]string{
+	"APPL": "Apple",
+	"MSFT": "Microsoft",
+	"SGI ": "Silicon Graphics",
+	"SUNW": "Sun Microsystems",
+	"TGNT": "Taligent",
+}
+
+var iccRenderingIntentNames = []string{
+	"Perceptual",
+	"Media-relative colorimetric",
+	"Saturation",
+	"ICC-absolute colorimetric",
+}
+
+var iccTagNames = map[string]string{
+	"A2B0": "AToB0",
+	"A2B1": "AToB1",
+	"A2B2": "AToB2",
+	"B2A0": "BToA0",
+	"B2A1": "BToA1",
+	"B2A2": "BToA2",
+	"bkpt": "mediaBlackPoint",
+	"bXYZ": "blueMatrixColumn",
+	"bTRC": "blueTRC",
+	"chad": "chromaticAdaptation",
+	"chrm": "chromaticity",
+	"cprt": "copyright",
+	"desc": "profileDescription",
+	"dmdd": "deviceModelDesc",
+	"dmnd": "deviceMfgDesc",
+	"gamt": "gamut",
+	"gXYZ": "greenMatrixColumn",
+	"gTRC": "greenTRC",
+	"kTRC": "grayTRC",
+	"lumi": "luminance",
+	"meas": "measurement",
+	"rXYZ": "redMatrixColumn",
+	"rTRC": "redTRC",
+	"tech": "technology",
+	"vued": "viewingCondDesc",
+	"view": "viewingConditions",
+	"wtpt": "mediaWhitePoint",
+}
+
+// The maximum number of tags listed by inspectICCProfile.
+const maxICCTagsListed = 100
+
+// Format a 4-byte ICC signature: as a quoted string if it's printable,
+// otherwise in hex. names, if not nil, gives names for known signatures.
+func formatICCSignature(names map[string]string, sig []byte) string {
+	v := binary.BigEndian.Uint32(sig)
+	if v == 0 {
+		return "0 (none)"
+	}
+	s := fmt.Sprintf("0x%08x", v)
+	if !isFOURCCPrintable(v) {
+		return s
+	}
+	s = fmt.Sprintf("%+q", string(sig))
+	if name, ok := names[string(sig)]; ok {
+		s += " = " + name
+	}
+	return s
+}
+
+// Format a tag signature. Unknown tags are shown in hex, even if they are
+// printable.
+func formatICCTagSignature(sig []byte) string {
+	if _, ok := iccTagNames[string(sig)]; ok {
+		return formatICCSignature(iccTagNames, sig)
+	}
+	return fmt.Sprintf("0x%08x", binary.BigEndian.Uint32(sig))
+}
+
+// Print a field of an ICC profile header, which starts at ctx.pos. This is
+// like pfxPrintfWithRaw, which can't be used because ICC profiles are
+// big-endian. The first of a is the field's value, for -format=json.
+func iccPfxPrintf(ctx *ctx_type, d []byte, offset int64, size int64,
+	fieldName string, format string, a ...interface{}) {
+	if ctx.rawBytes {
+		ctx.pendingRawBytes = fmt.Sprintf("% x", d[offset:offset+size])
+	}
+	ctx.pfxPrintf(offset, fieldName, format, a...)
+}
+
+// Print the fields of an ICC profile's header, and its tag table.
+func inspectICCProfile(ctx *ctx_type, d []byte) {
+	if len(d) < 128 {
+		startLine(ctx, 0)
+		ctx.print("(Too small for an ICC profile header)\n")
+		return
+	}
+
+	// The field names are the ICC's, with no "bV5" prefix.
+	saveFieldNamePrefix := ctx.fieldNamePrefix
+	ctx.fieldNamePrefix = ""
+	defer func() { ctx.fieldNamePrefix = saveFieldNamePrefix }()
+
+	iccPfxPrintf(ctx, d, 0, 4, "Profile size", "%v\n", binary.BigEndian.Uint32(d[0:4]))
+	iccPfxPrintf(ctx, d, 4, 4, "CMM type", "%s\n", formatICCSignature(nil, d[4:8]))
+	iccPfxPrintf(ctx, d, 8, 4, "Version", "%s\n",
+		fmt.Sprintf("%d.%d.%d", d[8], d[9]>>4, d[9]&0x0f))
+	iccPfxPrintf(ctx, d, 12, 4, "Device class", "%s\n",
+		formatICCSignature(iccDeviceClassNames, d[12:16]))
+	iccPfxPrintf(ctx, d, 16, 4, "Color space", "%s\n",
+		formatICCSignature(iccColorSpaceNames, d[16:20]))
+	iccPfxPrintf(ctx, d, 20, 4, "PCS", "%s\n", formatICCSignature(iccColorSpaceNames, d[20:24]))
+
+	var t [6]uint16
+	for i := range t {
+		t[i] = binary.BigEndian.Uint16(d[24+2*i : 26+2*i])
+	}
+	iccPfxPrintf(ctx, d, 24, 12, "Date/time", "%s\n",
+		fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t[0], t[1], t[2], t[3], t[4], t[5]))
+
+	iccPfxPrintf(ctx, d, 36, 4, "File signature", "%s", formatICCSignature(nil, d[36:40]))
+	if string(d[36:40]) != "acsp" {
+		ctx.print(" (should be \"acsp\")")
+	}
+	ctx.print("\n")
+
+	iccPfxPrintf(ctx, d, 40, 4, "Primary platform", "%s\n",
+		formatICCSignature(iccPlatformNames, d[40:44]))
+
+	flags := binary.BigEndian.Uint32(d[44:48])
+	iccPfxPrintf(ctx, d, 44, 4, "Flags", "0x%08x", flags)
+	if flags&0x1 != 0 {
+		ctx.print(" (embedded)")
+	}
+	if flags&0x2 != 0 {
+		ctx.print(" (cannot be used independently)")
+	}
+	ctx.print("\n")
+
+	iccPfxPrintf(ctx, d, 48, 4, "Device manufacturer", "%s\n", formatICCSignature(nil, d[48:52]))
+	iccPfxPrintf(ctx, d, 52, 4, "Device model", "%s\n", formatICCSignature(nil, d[52:56]))
+
+	intent := binary.BigEndian.Uint32(d[64:68])
+	iccPfxPrintf(ctx, d, 64, 4, "Rendering intent", "%v", intent)
+	if int64(intent) < int64(len(iccRenderingIntentNames)) {
+		ctx.printf(" = %s", iccRenderingIntentNames[intent])
+	}
+	ctx.print("\n")
+
+	iccPfxPrintf(ctx, d, 80, 4, "Profile creator", "%s\n", formatICCSignature(nil, d[80:84]))
+
+	if len(d) < 132 {
+		return
+	}
+	tagCount := binary.BigEndian.Uint32(d[128:132])
+	iccPfxPrintf(ctx, d, 128, 4, "Tag count", "%v\n", tagCount)
+	for i := int64(0); i < int64(tagCount); i++ {
+		pos := 132 + 12*i
+		if pos+12 > int64(len(d)) {
+			startLine(ctx, pos)
+			ctx.print("(Tag table is truncated)\n")
+			break
+		}
+		if i >= maxICCTagsListed {
+			startLine(ctx, pos)
+			ctx.printf("(%v more tags not listed)\n", int64(tagCount)-i)
+			break
+		}
+		startLine(ctx, pos)
+		ctx.printf("Tag %s: offset %v, size %v\n", formatICCTagSignature(d[pos:pos+4]),
+			binary.BigEndian.Uint32(d[pos+4:pos+8]), binary.BigEndian.Uint32(d[pos+8:pos+12]))
+	}
+}
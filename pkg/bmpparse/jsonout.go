@@ -0,0 +1,253 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/jsonout.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "encoding/hex"
+import "encoding/json"
+import "strings"
+
+// With -format=json, the file is inspected with the usual output suppressed,
+// and the header fields, pixel rows, etc. are collected into an
+// inspectionResult, which is printed at the end.
+
+// One field, as printed by pfxPrintf.
+type jsonField struct {
+	Name   string      `json:"name"`
+	Offset int64       `json:"offset"`
+	Value  interface{} `json:"value"`
+	// The rest of the line that would have been printed: the value, and
+	// any decoded annotation.
+	Annotation string `json:"annotation"`
+}
+
+type jsonPaletteEntry struct {
+	Index int    `json:"index"`
+	Color string `json:"color"` // "#rrggbb"
+}
+
+type jsonRow struct {
+	Row    int    `json:"row"` // The logical row number (0 = top)
+	Pixels string `json:"pixels"`
+}
+
+type jsonPixelData struct {
+	Offset int64 `json:"offset"`
+	// Uncompressed rows, without padding; or for RLE-compressed images,
+	// the decoded pixels, one byte per pixel (three for RLE24).
+	Rows []jsonRow `json:"rows"`
+}
+
+type jsonProfile struct {
+	Offset  int64  `json:"offset"`
+	Size    int64  `json:"size"`
+	Linked  bool   `json:"linked"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type jsonCompressionStats struct {
+	Compression      string  `json:"compression"`
+	CompressedSize   int64   `json:"compressedSize,omitempty"`
+	UncompressedSize int64   `json:"uncompressedSize,omitempty"`
+	Ratio            float64 `json:"ratio,omitempty"` // compressed/uncompressed
+	RLERuns          int     `json:"rleRuns,omitempty"`
+	RLERows          int     `json:"rleRows,omitempty"`
+	MaxRLERunsInRow  int     `json:"maxRLERunsInRow,omitempty"`
+}
+
+type inspectionResult struct {
+	File             string               `json:"file"`
+	FileSize         int64                `json:"fileSize"`
+	Version          string               `json:"version,omitempty"`
+	VersionName      string               `json:"versionName,omitempty"`
+	FileHeader       []jsonField          `json:"fileHeader,omitempty"`
+	InfoHeader       []jsonField          `json:"infoHeader,omitempty"`
+	Bitfields        []jsonField          `json:"bitfields,omitempty"`
+	Other            []jsonField          `json:"other,omitempty"`
+	Palette          []jsonPaletteEntry   `json:"palette,omitempty"`
+	PixelData        *jsonPixelData       `json:"pixelData,omitempty"`
+	Profile          *jsonProfile         `json:"profile,omitempty"`
+	CompressionStats jsonCompressionStats `json:"compressionStats"`
+	Warnings         []string             `json:"warnings"`
+	Error            string               `json:"error,omitempty"`
+
+	// The field whose line is being printed, if any.
+	pendingField *jsonField
+	// The value read by pfxPrintfWithRaw for the next field.
+	pendingValue interface{}
+}
+
+// Called by pfxPrintf after the field name has been printed.
+func (ctx *ctx_type) beginJSONField(pos int64, fieldName string, a []interface{}) {
+	r := ctx.json
+	if r == nil {
+		return
+	}
+	r.endJSONField()
+
+	f := jsonField{Name: translateFieldName(ctx, fieldName), Offset: pos}
+	if r.pendingValue != nil {
+		f.Value = r.pendingValue
+		r.pendingValue = nil
+	} else if len(a) > 0 {
+		f.Value = a[0]
+	}
+
+	var list *[]jsonField
+	switch ctx.section {
+	case "fileheader":
+		list = &r.FileHeader
+	case "infoheader":
+		list = &r.InfoHeader
+	case "bitfields":
+		list = &r.Bitfields
+	default:
+		list = &r.Other
+	}
+	*list = append(*list, f)
+	r.pendingField = &(*list)[len(*list)-1]
+}
+
+// Note the raw value of the next field, from its bytes.
+func (r *inspectionResult) setPendingValue(d []byte) {
+	switch len(d) {
+	case 2:
+		r.pendingValue = getWORD(d)
+	case 4:
+		r.pendingValue = getDWORD(d)
+	default:
+		r.pendingValue = hex.EncodeToString(d)
+	}
+}
+
+// Set the value of the next field, for fields whose value is not the first
+// argument to pfxPrintf.
+func (ctx *ctx_type) setJSONValue(v interface{}) {
+	if ctx.json != nil {
+		ctx.json.pendingValue = v
+	}
+}
+
+// Called for all text printed. Text on the line of a field becomes its
+// annotation.
+func (r *inspectionResult) captureText(s string) {
+	if r.pendingField == nil {
+		return
+	}
+	i := strings.IndexByte(s, '\n')
+	if i < 0 {
+		r.pendingField.Annotation += s
+		return
+	}
+	r.pendingField.Annotation += s[:i]
+	r.endJSONField()
+}
+
+func (r *inspectionResult) endJSONField() {
+	if r.pendingField != nil {
+		r.pendingField.Annotation = strings.TrimSpace(r.pendingField.Annotation)
+		r.pendingField = nil
+	}
+}
+
+func (r *inspectionResult) addRow(ctx *ctx_type, rowLogical int, pixels []byte) {
+	if r.PixelData == nil {
+		r.PixelData = &jsonPixelData{Offset: ctx.pos}
+	}
+	r.PixelData.Rows = append(r.PixelData.Rows, jsonRow{Row: rowLogical,
+		Pixels: hex.EncodeToString(pixels)})
+}
+
+// Called at the end of the RLE data.
+func (r *inspectionResult) setRLEStats(rlectx *rlectx_type) {
+	r.CompressionStats.RLERuns = rlectx.totalRuns
+	r.CompressionStats.RLERows = rlectx.numRows
+	r.CompressionStats.MaxRLERunsInRow = rlectx.maxRunsInRow
+}
+
+// With -format=json, the RLE statistics are reported even when the pixels
+// are not decoded because the image is top-down. The data is decoded as if
+// the image were bottom-up, and everything but the statistics is discarded.
+func collectRLEStats(ctx *ctx_type) {
+	switch ctx.compressionType {
+	case "rle4", "rle8", "rle24":
+	default:
+		return
+	}
+	r := ctx.json
+	saveWarnings := ctx.warnings
+	saveScore := ctx.score
+	savePixelData := r.PixelData
+	ctx.score = validityScore{}
+	printRLECompressedPixels(ctx, ctx.file.section(ctx.pos, ctx.fileSize))
+	ctx.warnings = saveWarnings
+	ctx.score = saveScore
+	r.PixelData = savePixelData
+}
+
+// Fill in the parts of the result that don't come from the printed output.
+// readBmp must have been called.
+func finishInspectionResult(ctx *ctx_type, r *inspectionResult) {
+	r.endJSONField()
+	r.File = ctx.fileName
+	r.FileSize = ctx.fileSize
+	r.Version = ctx.bmpVerID
+	r.VersionName = ctx.bmpVerName
+	r.Warnings = ctx.warnings
+	if r.Warnings == nil {
+		r.Warnings = []string{}
+	}
+
+	if ctx.palNumEntries > 0 && ctx.palPos > 0 {
+		for i, c := range getPaletteRGB(ctx) {
+			r.Palette = append(r.Palette, jsonPaletteEntry{Index: i,
+				Color: "#" + hex.EncodeToString(c[:])})
+		}
+	}
+
+	if ctx.hasProfile && ctx.profileOffset > 0 && ctx.profileOffset+ctx.profileSize <= ctx.fileSize {
+		d := ctx.file.bytes(ctx.profileOffset, ctx.profileOffset+ctx.profileSize)
+		p := &jsonProfile{Offset: ctx.profileOffset, Size: ctx.profileSize,
+			Linked: ctx.profileIsLinked}
+		if ctx.profileIsLinked {
+			if i := bytes.IndexByte(d, 0); i >= 0 {
+				d = d[:i]
+			}
+			p.Summary = string(d)
+		} else {
+			p.Summary = iccProfileSummary(d)
+		}
+		r.Profile = p
+	}
+
+	cs := &r.CompressionStats
+	cs.Compression = ctx.compressionType
+	if ctx.actualBitsSize > 0 && ctx.calculatedSize > 0 {
+		cs.CompressedSize = ctx.actualBitsSize
+		cs.UncompressedSize = ctx.calculatedSize
+		cs.Ratio = float64(ctx.actualBitsSize) / float64(ctx.calculatedSize)
+	}
+}
+
+// Inspect the file, and print the result as JSON.
+func printInspectionJSON(ctx *ctx_type) error {
+	r := new(inspectionResult)
+	ctx.json = r
+	ctx.suppressOutput = true
+	err := readBmp(ctx)
+	ctx.suppressOutput = false
+	ctx.json = nil
+
+	finishInspectionResult(ctx, r)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	d, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	ctx.printf("%s\n", d)
+	return nil
+}
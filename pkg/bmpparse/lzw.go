@@ -0,0 +1,99 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/lzw.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// An estimate of how well the pixels would compress with LZW, as used by
+// GIF, for -lzw-estimate.
+
+const (
+	lzwMinCodeSize = 9  // Code size in bits, after the 256 literal codes
+	lzwMaxCodeSize = 12 // As in GIF; the dictionary is reset when it's full
+)
+
+// Return the number of bytes that LZW compression of data would produce.
+// The codes are not actually written; only their sizes are counted.
+func estimateLZW(data []byte) int64 {
+	type key struct {
+		prefix int
+		b      byte
+	}
+	const clearCode = 256
+	const firstCode = 258 // After the clear and end-of-data codes
+
+	var dict map[key]int
+	var nextCode, codeSize int
+	var numBits int64
+	reset := func() {
+		dict = make(map[key]int)
+		nextCode = firstCode
+		codeSize = lzwMinCodeSize
+	}
+	reset()
+
+	if len(data) == 0 {
+		return 0
+	}
+	numBits += int64(codeSize) // The initial clear code
+	prefix := int(data[0])
+	for _, b := range data[1:] {
+		if code, ok := dict[key{prefix, b}]; ok {
+			prefix = code
+			continue
+		}
+		numBits += int64(codeSize)
+		dict[key{prefix, b}] = nextCode
+		nextCode++
+		if nextCode > 1<<uint(codeSize) {
+			if codeSize < lzwMaxCodeSize {
+				codeSize++
+			} else {
+				numBits += int64(codeSize) // A clear code
+				reset()
+			}
+		}
+		prefix = int(b)
+	}
+	numBits += 2 * int64(codeSize) // The last prefix, and the end code
+	return (numBits + 7) / 8
+}
+
+// Return the bytes that estimateLZW should measure, for an uncompressed
+// image: one byte per pixel for indexed images, as GIF would store them.
+// For other images, the row padding is removed, and each byte is replaced
+// by its difference from the same byte of the previous pixel (as with PNG's
+// "Sub" filter), which usually gives a better estimate.
+func lzwInputBytes(ctx *ctx_type, d []byte) []byte {
+	var out []byte
+	bytesPerPixel := ctx.bitCount / 8
+	for j := 0; j < ctx.imgHeight; j++ {
+		row := d[int64(j)*ctx.rowStride : int64(j+1)*ctx.rowStride]
+		if ctx.bitCount <= 8 {
+			for i := 0; i < ctx.imgWidth; i++ {
+				out = append(out, byte(getPixelValue(row, i, ctx.bitCount)))
+			}
+			continue
+		}
+		row = row[:ctx.imgWidth*bytesPerPixel]
+		for i := range row {
+			if i < bytesPerPixel {
+				out = append(out, row[i])
+			} else {
+				out = append(out, row[i]-row[i-bytesPerPixel])
+			}
+		}
+	}
+	return out
+}
+
+func reportLZWEstimate(ctx *ctx_type, d []byte) {
+	if ctx.imgWidth < 1 || ctx.imgHeight < 1 || printRowFuncs[ctx.bitCount] == nil {
+		return
+	}
+	n := ctx.calculatedSize
+	m := estimateLZW(lzwInputBytes(ctx, d))
+	startLine(ctx, 0)
+	ctx.printf("(LZW estimate: %v → %v bytes = %.1f%% of original)\n", n, m,
+		100*float64(m)/float64(n))
+}
@@ -0,0 +1,74 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/palettedump.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "fmt"
+import "io/ioutil"
+
+// Return the R, G, B values of each color table entry.
+func getPaletteRGB(ctx *ctx_type) [][3]byte {
+	d := getPaletteBytes(ctx)
+	pal := make([][3]byte, ctx.palNumEntries)
+	for i := range pal {
+		e := d[i*ctx.palBytesPerEntry:]
+		pal[i] = [3]byte{e[2], e[1], e[0]}
+	}
+	return pal
+}
+
+// Print a note about palettes that are larger than any image can use. Called
+// before writing a palette file.
+func notePaletteDumpSize(ctx *ctx_type) {
+	if ctx.palNumEntries > 256 {
+		startLineAbsolute(ctx, ctx.fileSize)
+		ctx.printf("(Note: The palette has %v entries; no image can use more than 256)\n",
+			ctx.palNumEntries)
+	}
+}
+
+// Write the palette to a text file, one entry per line.
+func writePaletteText(ctx *ctx_type, outputPath string) error {
+	var buf bytes.Buffer
+
+	if ctx.palNumEntries == 0 {
+		buf.WriteString("(no palette)\n")
+	}
+	for i, c := range getPaletteRGB(ctx) {
+		fmt.Fprintf(&buf, "0x%02x: #%02x%02x%02x (R=%02x, G=%02x, B=%02x)\n",
+			i, c[0], c[1], c[2], c[0], c[1], c[2])
+	}
+
+	err := ioutil.WriteFile(outputPath, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.printf("(Wrote palette to %s)\n", outputPath)
+	return nil
+}
+
+// Write the palette as CSS custom properties, named --color-0, --color-1,
+// etc.
+func writePaletteCSS(ctx *ctx_type, outputPath string) error {
+	var buf bytes.Buffer
+
+	buf.WriteString(":root {\n")
+	if ctx.palNumEntries == 0 {
+		buf.WriteString("  /* no palette */\n")
+	}
+	for i, c := range getPaletteRGB(ctx) {
+		fmt.Fprintf(&buf, "  --color-%d: #%02x%02x%02x;\n", i, c[0], c[1], c[2])
+	}
+	buf.WriteString("}\n")
+
+	err := ioutil.WriteFile(outputPath, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.printf("(Wrote palette CSS to %s)\n", outputPath)
+	return nil
+}
@@ -0,0 +1,84 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/palettepreview.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "os"
+
+// The levels of each sample in the 6×6×6 color cube of the xterm 256-color
+// palette (colors 16-231).
+var xtermCubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func colorDistanceSq(r1, g1, b1, r2, g2, b2 int) int {
+	return (r1-r2)*(r1-r2) + (g1-g2)*(g1-g2) + (b1-b2)*(b1-b2)
+}
+
+// Return the xterm 256-color palette index nearest to the given color,
+// considering only the color cube and the gray ramp (colors 232-255).
+func nearestXterm256Color(r, g, b int) int {
+	nearestLevel := func(v int) int {
+		best := 0
+		for i, l := range xtermCubeLevels {
+			if abs(v-l) < abs(v-xtermCubeLevels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	ri, gi, bi := nearestLevel(r), nearestLevel(g), nearestLevel(b)
+	bestIndex := 16 + 36*ri + 6*gi + bi
+	bestDist := colorDistanceSq(r, g, b, xtermCubeLevels[ri], xtermCubeLevels[gi],
+		xtermCubeLevels[bi])
+
+	for i := 0; i < 24; i++ {
+		v := 8 + 10*i
+		if d := colorDistanceSq(r, g, b, v, v, v); d < bestDist {
+			bestIndex = 232 + i
+			bestDist = d
+		}
+	}
+	return bestIndex
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Report whether the terminal is known to support 24-bit color.
+func terminalHasTrueColor() bool {
+	ct := os.Getenv("COLORTERM")
+	return ct == "truecolor" || ct == "24bit"
+}
+
+// Print the palette as blocks of color, 4 entries per line, using ANSI
+// escape codes. Called after inspectColorTable.
+func printPalettePreview(ctx *ctx_type) {
+	const entriesPerLine = 4
+	trueColor := terminalHasTrueColor()
+
+	pal := getPaletteRGB(ctx)
+	for i, c := range pal {
+		if i%entriesPerLine == 0 {
+			startLineAbsolute(ctx, ctx.palPos+int64(i*ctx.palBytesPerEntry))
+		} else {
+			ctx.print("  ")
+		}
+
+		if trueColor {
+			ctx.printf("[%3d] \x1b[48;2;%d;%d;%dm        \x1b[0m", i, c[0], c[1], c[2])
+		} else {
+			ctx.printf("[%3d] \x1b[48;5;%dm        \x1b[0m", i,
+				nearestXterm256Color(int(c[0]), int(c[1]), int(c[2])))
+		}
+		ctx.printf(" #%02x%02x%02x R:%02x G:%02x B:%02x", c[0], c[1], c[2], c[0], c[1], c[2])
+
+		if i%entriesPerLine == entriesPerLine-1 || i == len(pal)-1 {
+			ctx.print("\n")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/palettesort.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "math"
+import "sort"
+
+// The orders that -palette-sort can use. "index" is the normal order.
+var paletteSortModes = []string{"index", "luminance", "hue", "saturation"}
+
+func isPaletteSortMode(mode string) bool {
+	for _, m := range paletteSortModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Perceived luminance, from 0 to 1.
+func paletteEntryLuminance(e paletteEntry_type) float64 {
+	return (0.299*float64(e.r) + 0.587*float64(e.g) + 0.114*float64(e.b)) / 255
+}
+
+// Return the HSV hue (0 to 360 degrees; 0 for grays) and saturation (0 to
+// 1) of e.
+func paletteEntryHueSat(e paletteEntry_type) (float64, float64) {
+	r, g, b := float64(e.r)/255, float64(e.g)/255, float64(e.b)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	delta := max - min
+	if delta == 0 {
+		return 0, 0
+	}
+
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, delta / max
+}
+
+// The value that entries are sorted by, for the given mode.
+func paletteSortKey(e paletteEntry_type, mode string) float64 {
+	switch mode {
+	case "luminance":
+		return paletteEntryLuminance(e)
+	case "hue":
+		h, _ := paletteEntryHueSat(e)
+		return h
+	case "saturation":
+		_, s := paletteEntryHueSat(e)
+		return s
+	}
+	return float64(e.index)
+}
+
+// Return a copy of entries, sorted from lowest to highest by the given mode.
+// Entries with the same value stay in index order.
+func sortPalette(entries []paletteEntry_type, mode string) []paletteEntry_type {
+	sorted := make([]paletteEntry_type, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return paletteSortKey(sorted[i], mode) < paletteSortKey(sorted[j], mode)
+	})
+	return sorted
+}
+
+// Print the palette again, in the order given by -palette-sort. Called after
+// the color table has been printed.
+func printSortedPalette(ctx *ctx_type) {
+	mode := ctx.paletteSort
+	pal := getPaletteRGB(ctx)
+	entries := make([]paletteEntry_type, len(pal))
+	for i, c := range pal {
+		entries[i] = paletteEntry_type{i, c[0], c[1], c[2]}
+	}
+
+	startLine(ctx, 0)
+	ctx.printf("----- Palette sorted by %s -----\n", mode)
+	for _, e := range sortPalette(entries, mode) {
+		startLine(ctx, int64(e.index*ctx.palBytesPerEntry))
+		if ctx.bitCount <= 4 {
+			ctx.printf("   %x = ", e.index)
+		} else if ctx.bitCount <= 8 {
+			ctx.printf("  %02x = ", e.index)
+		} else {
+			ctx.printf("[%3d]  ", e.index)
+		}
+		ctx.printf("#%02x%02x%02x", e.r, e.g, e.b)
+		switch mode {
+		case "luminance":
+			ctx.printf(" (lum=%.2f)", paletteEntryLuminance(e))
+		case "hue":
+			h, _ := paletteEntryHueSat(e)
+			ctx.printf(" (hue=%.0f)", h)
+		case "saturation":
+			_, s := paletteEntryHueSat(e)
+			ctx.printf(" (sat=%.2f)", s)
+		}
+		ctx.print("\n")
+	}
+}
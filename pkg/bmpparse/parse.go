@@ -0,0 +1,162 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/parse.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bufio"
+import "image/color"
+import "io"
+import "strings"
+import "github.com/jsummers/bmpinspect/pkg/bmpinspect"
+
+// The structures in a Context are the ones defined by package bmpinspect.
+type (
+	FileHeader = bmpinspect.FileHeader
+	InfoHeader = bmpinspect.InfoHeader
+	Palette    = []color.RGBA
+	BitmapBits = bmpinspect.BitmapBits
+)
+
+// Line is one line of the description of a file.
+type Line struct {
+	// The file offset that the line is about, or -1 if it has none.
+	Offset int64
+	// If the line shows a header field, the field's name, as printed.
+	Field string
+	// The text of the line, not including the offset or the newline.
+	Text string
+}
+
+// Context is the result of parsing a BMP file with Parse.
+type Context struct {
+	// The headers, palette, and location of the bitmap bits, as read while
+	// parsing. They are nil if the file is not a plain BMP file (a RIFF or
+	// Bitmap Array file, for example), or if parsing stopped before they
+	// were read.
+	FileHeader *FileHeader
+	InfoHeader InfoHeader
+	Palette    Palette
+	Bits       *BitmapBits
+
+	// The text of each warning.
+	Warnings []string
+
+	// The description of the file, as printed by Print.
+	Lines []Line
+}
+
+// Collects the output as Lines.
+type lineRecorder struct {
+	lines []Line
+	cur   Line
+	// Whether anything has been recorded for cur.
+	started bool
+
+	// Set while the offset at the start of a line is being printed.
+	inOffset bool
+	offset   int64
+}
+
+// Called while the offset at the start of a line is printed. The returned
+// function must be called after it has been printed.
+func (r *lineRecorder) beginLine(pos int64) func() {
+	r.inOffset = true
+	r.offset = pos
+	return func() { r.inOffset = false }
+}
+
+// Note that the current line shows the named header field.
+func (r *lineRecorder) setField(name string) {
+	if r.started && r.cur.Offset >= 0 && r.cur.Text == "" {
+		r.cur.Field = name
+	}
+}
+
+func (r *lineRecorder) write(s string) {
+	if r.inOffset && !r.started {
+		r.cur.Offset = r.offset
+		r.started = true
+		return
+	}
+	// An offset printed in the middle of a line is just text.
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			r.cur.Text += s
+			r.started = true
+			return
+		}
+		r.cur.Text += s[:i]
+		r.lines = append(r.lines, r.cur)
+		r.cur = Line{Offset: -1}
+		r.started = false
+		s = s[i+1:]
+	}
+}
+
+// Return the recorded lines, including an unfinished last line.
+func (r *lineRecorder) finish() []Line {
+	if r.started {
+		r.lines = append(r.lines, r.cur)
+	}
+	return r.lines
+}
+
+// Parse reads the BMP file from r, and parses it with the default options,
+// without printing anything. The file is read as it is parsed; the pixel
+// data is not read into memory all at once. The description that the bmpinspect command
+// would print is recorded in the Context, for Print.
+//
+// If the file is damaged, the error is returned along with a Context that
+// describes the part of the file that was parsed.
+func Parse(r io.ReadSeeker) (*Context, error) {
+	ctx := new(ctx_type)
+	ctx.paletteSort = "index"
+	ctx.printPixels = true
+	ctx.compressionType = "none"
+
+	err := readBmpData(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return parseBmp(ctx)
+}
+
+// Parse ctx.file with the options already set in ctx, as Parse does.
+func parseBmp(ctx *ctx_type) (*Context, error) {
+	rec := &lineRecorder{cur: Line{Offset: -1}}
+	ctx.recorder = rec
+	err := readBmp(ctx)
+
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.print("----- End of file -----\n")
+
+	if ctx.stats != nil {
+		printImageStatistics(ctx, *ctx.stats)
+	}
+	ctx.recorder = nil
+
+	c := &Context{FileHeader: ctx.fileHeader, InfoHeader: ctx.infoHeader,
+		Bits: ctx.bits, Warnings: ctx.warnings, Lines: rec.finish()}
+	if ctx.palNumEntries > 0 && ctx.palPos > 0 {
+		for _, e := range getPaletteRGB(ctx) {
+			c.Palette = append(c.Palette, color.RGBA{R: e[0], G: e[1], B: e[2], A: 255})
+		}
+	}
+	return c, err
+}
+
+// Print writes the description of the file parsed by Parse, in the same
+// form that the bmpinspect command prints it.
+func Print(c *Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, ln := range c.Lines {
+		if ln.Offset >= 0 {
+			bw.WriteString(formatLineOffset(ln.Offset))
+		}
+		bw.WriteString(ln.Text)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,123 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/parse_test.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+import "github.com/jsummers/bmpinspect/pkg/bmpinspect"
+
+// The golden BMP files are shared with package bmpinspect.
+var testDataDir = filepath.Join("..", "bmpinspect", "testdata")
+
+// Parse one of the golden BMP files.
+func parseTestFile(t *testing.T, name string) *Context {
+	f, err := os.Open(filepath.Join(testDataDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	c, err := Parse(f)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return c
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		fileHeader    FileHeader
+		headerSize    uint32
+		palNumEntries int
+		bits          BitmapBits
+	}{
+		{"pal4.bmp", FileHeader{Type: "BM", Size: 126, OffBits: 118}, 40, 16, BitmapBits{Offset: 118, Size: 8}},
+		{"rgb24.bmp", FileHeader{Type: "BM", Size: 90, OffBits: 54}, 40, 0, BitmapBits{Offset: 54, Size: 36}},
+		{"v5_32.bmp", FileHeader{Type: "BM", Size: 154, OffBits: 138}, 124, 0, BitmapBits{Offset: 138, Size: 16}},
+		{"os2v1.bmp", FileHeader{Type: "BM", Size: 82, OffBits: 74}, 12, 16, BitmapBits{Offset: 74, Size: 8}},
+	}
+	for _, tt := range tests {
+		c := parseTestFile(t, tt.name)
+		if c.FileHeader == nil || *c.FileHeader != tt.fileHeader {
+			t.Errorf("%s: FileHeader = %+v, want %+v", tt.name, c.FileHeader, tt.fileHeader)
+		}
+		if c.InfoHeader == nil || c.InfoHeader.HeaderSize() != tt.headerSize {
+			t.Errorf("%s: InfoHeader = %+v, want size %v", tt.name, c.InfoHeader, tt.headerSize)
+		}
+		if len(c.Palette) != tt.palNumEntries {
+			t.Errorf("%s: %v palette entries, want %v", tt.name, len(c.Palette), tt.palNumEntries)
+		}
+		if c.Bits == nil || *c.Bits != tt.bits {
+			t.Errorf("%s: Bits = %+v, want %+v", tt.name, c.Bits, tt.bits)
+		}
+		if len(c.Warnings) != 0 {
+			t.Errorf("%s: unexpected warnings: %v", tt.name, c.Warnings)
+		}
+	}
+
+	c := parseTestFile(t, "rgb24.bmp")
+	want := bmpinspect.BitmapInfoHeader{Size: 40, Width: 4, Height: 3, Planes: 1,
+		BitCount: 24, SizeImage: 36, XPelsPerMeter: 2835, YPelsPerMeter: 2835}
+	if h, ok := c.InfoHeader.(*bmpinspect.BitmapInfoHeader); !ok || *h != want {
+		t.Errorf("rgb24.bmp: InfoHeader = %+v, want %+v", c.InfoHeader, want)
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	c := parseTestFile(t, "rgb24.bmp")
+	want := Line{Offset: 18, Field: "biWidth", Text: "biWidth: 4"}
+	for _, ln := range c.Lines {
+		if ln.Field == want.Field {
+			if ln != want {
+				t.Errorf("got %+v, want %+v", ln, want)
+			}
+			return
+		}
+	}
+	t.Errorf("no %s line", want.Field)
+}
+
+// Print should write exactly what the bmpinspect command prints.
+func TestPrint(t *testing.T) {
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "rgb24.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := parseTestFile(t, "rgb24.bmp")
+	var buf bytes.Buffer
+	err = Print(c, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+// A truncated file is an error, but what was parsed can still be printed.
+func TestParseTruncated(t *testing.T) {
+	d, err := ioutil.ReadFile(filepath.Join(testDataDir, "rgb24.bmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Parse(bytes.NewReader(d[:40]))
+	if err == nil {
+		t.Fatal("no error for a truncated file")
+	}
+	if c == nil {
+		t.Fatal("no Context for a truncated file")
+	}
+	if len(c.Warnings) != 1 {
+		t.Errorf("got warnings %v, want 1", c.Warnings)
+	}
+	var buf bytes.Buffer
+	Print(c, &buf)
+	if !bytes.Contains(buf.Bytes(), []byte("----- End of file -----\n")) {
+		t.Errorf("incomplete output:\n%s", buf.String())
+	}
+}
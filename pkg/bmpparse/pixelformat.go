@@ -0,0 +1,105 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/pixelformat.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "sort"
+import "strings"
+
+// The -interpret-as-format option displays the pixels as if they were in a
+// different format than the header says, to help diagnose files whose
+// header does not match the pixel data.
+
+type pixelFormat_type struct {
+	bitCount int
+	printRow printRowFuncType
+}
+
+var pixelFormats = map[string]pixelFormat_type{
+	"1bpp":       {1, printRow_1},
+	"4bpp":       {4, printRow_4},
+	"8bpp":       {8, printRow_8},
+	"16bpp-555":  {16, printRow_16_555},
+	"16bpp-565":  {16, printRow_16_565},
+	"24bpp":      {24, printRow_24},
+	"32bpp-rgb":  {32, printRow_32_rgb},
+	"32bpp-argb": {32, printRow_32_argb},
+	"32bpp-bgra": {32, printRow_32_bgra},
+}
+
+// Return the valid names for -interpret-as-format, as a comma-separated list.
+func pixelFormatNames() string {
+	var names []string
+	for name := range pixelFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Print 16-bit pixels as RGB, using the given masks.
+func printRow_16_masked(ctx *ctx_type, d []byte, masks [4]uint32) {
+	for i := 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*2), -1)
+		v := uint32(getWORD(d[i*2 : i*2+2]))
+		ctx.printf(" %02x%02x%02x", getMaskedChannel(v, masks[0]),
+			getMaskedChannel(v, masks[1]), getMaskedChannel(v, masks[2]))
+	}
+}
+
+func printRow_16_555(ctx *ctx_type, d []byte) {
+	printRow_16_masked(ctx, d, [4]uint32{0x7c00, 0x03e0, 0x001f, 0})
+}
+
+func printRow_16_565(ctx *ctx_type, d []byte) {
+	printRow_16_masked(ctx, d, [4]uint32{0xf800, 0x07e0, 0x001f, 0})
+}
+
+// 32-bit pixels, stored in B, G, R, (unused) order. Prints RRGGBB.
+func printRow_32_rgb(ctx *ctx_type, d []byte) {
+	for i := 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*4), -1)
+		ctx.printf(" %06x", getDWORD(d[i*4:i*4+4])&0xffffff)
+	}
+}
+
+// 32-bit pixels, stored in B, G, R, A order. Prints AARRGGBB.
+func printRow_32_argb(ctx *ctx_type, d []byte) {
+	for i := 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*4), -1)
+		ctx.printf(" %08x", getDWORD(d[i*4:i*4+4]))
+	}
+}
+
+// 32-bit pixels, stored in A, R, G, B order. Prints AARRGGBB.
+func printRow_32_bgra(ctx *ctx_type, d []byte) {
+	for i := 0; i < ctx.imgWidth; i++ {
+		ctx.printPixelOffset(ctx.rowFileOffset+int64(i*4), -1)
+		ctx.printf(" %02x%02x%02x%02x", d[i*4], d[i*4+1], d[i*4+2], d[i*4+3])
+	}
+}
+
+// Print the pixels of an uncompressed image, using the format named by
+// ctx.pixelFormatOverride instead of the format in the header.
+func printOverriddenPixels(ctx *ctx_type, bitsLen int64) {
+	pf := pixelFormats[ctx.pixelFormatOverride]
+
+	startLine(ctx, 0)
+	ctx.printf("(Pixel format overridden to: %s)\n", ctx.pixelFormatOverride)
+
+	rowStride := (((int64(ctx.imgWidth) * int64(pf.bitCount)) + 31) / 32) * 4
+	if rowStride < 1 || rowStride > 1000000 {
+		return
+	}
+	if bitsLen < rowStride*int64(ctx.imgHeight) {
+		startLine(ctx, 0)
+		ctx.printf("(Not enough bytes for a %s image of this size)\n", ctx.pixelFormatOverride)
+		return
+	}
+	if pf.bitCount <= 8 && ctx.palNumEntries == 0 {
+		// Every palette index would be reported as bad.
+		ctx.badColorWarned = true
+	}
+	printPixelRows(ctx, pf.printRow, rowStride)
+}
@@ -0,0 +1,78 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/rawheader.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "fmt"
+import "strings"
+
+// Return the sizes of the fields of the infoheader, in order, for
+// -show-raw-infoheader. Only the layout of the header is needed, so it is
+// based on the header size and the detected version. Fields that start at or
+// beyond avail (the number of header bytes in the file) are omitted.
+func infoheaderFieldSizes(ctx *ctx_type, avail int) []int {
+	size := int(ctx.infoHeaderSize)
+	if size == 12 {
+		return []int{4, 2, 2, 2, 2}
+	}
+
+	// Size, width, height, planes, bitcount
+	sizes := []int{4, 4, 4, 2, 2}
+	pos := 16
+	if avail < size {
+		size = avail
+	}
+	for pos < size {
+		if ctx.bmpVerID == "os2v2" && pos >= 40 && pos < 48 {
+			// Units, Reserved, Recording, Rendering
+			sizes = append(sizes, 2)
+			pos += 2
+			continue
+		}
+		sizes = append(sizes, 4)
+		pos += 4
+	}
+	return sizes
+}
+
+// Print the bytes of the infoheader (as many as are in the file), grouped
+// by field, before they are parsed.
+func printRawInfoheader(ctx *ctx_type) {
+	const fieldsPerLine = 6
+	end := ctx.pos + int64(ctx.infoHeaderSize)
+	if end > ctx.file.size {
+		end = ctx.file.size
+	}
+	d := ctx.file.bytes(ctx.pos, end)
+
+	var groups []string
+	lineStart := 0
+	pos := 0
+	flush := func() {
+		if len(groups) > 0 {
+			startLine(ctx, int64(lineStart))
+			ctx.printf("(Raw: %s)\n", strings.Join(groups, " | "))
+			groups = groups[:0]
+		}
+	}
+
+	for _, n := range infoheaderFieldSizes(ctx, len(d)) {
+		if pos >= len(d) {
+			break
+		}
+		if len(groups) == 0 {
+			lineStart = pos
+		}
+		end := pos + n
+		if end > len(d) {
+			end = len(d)
+		}
+		groups = append(groups, fmt.Sprintf("%x", d[pos:end]))
+		pos = end
+		if len(groups) == fieldsPerLine {
+			flush()
+		}
+	}
+	flush()
+}
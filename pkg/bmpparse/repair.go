@@ -0,0 +1,96 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/repair.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+import "io/ioutil"
+import "encoding/binary"
+
+// Patch the fixable problems in the BMP file in ctx.file, and write the
+// result to outputPath. Must be called after the file has been successfully
+// inspected.
+func repairBmp(ctx *ctx_type, outputPath string) error {
+	var numFixed int
+
+	if ctx.fileType != "BM" || ctx.infoHeaderSize < 12 {
+		return errors.New("Cannot repair this file")
+	}
+
+	d := make([]byte, ctx.file.size)
+	copy(d, ctx.file.bytes(0, ctx.file.size))
+	isOS2 := ctx.bmpVerID == "os2v1" || ctx.bmpVerID == "os2v2"
+
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.print("----- Repair -----\n")
+
+	// FILEHEADER
+	bfSize := getDWORD(d[2:6])
+	if int64(bfSize) != ctx.fileSize && !(isOS2 && bfSize == 14+ctx.infoHeaderSize) {
+		binary.LittleEndian.PutUint32(d[2:6], uint32(ctx.fileSize))
+		fieldName := "bfSize"
+		if isOS2 {
+			fieldName = "cbSize"
+		}
+		ctx.printf("Fixed: %s %v → %v\n", fieldName, bfSize, ctx.fileSize)
+		numFixed++
+	}
+
+	// In OS/2 BMPs, these are hotspot fields, not reserved fields.
+	if !isOS2 {
+		for _, fieldOffset := range []int{6, 8} {
+			v := getWORD(d[fieldOffset : fieldOffset+2])
+			if v != 0 {
+				binary.LittleEndian.PutUint16(d[fieldOffset:fieldOffset+2], 0)
+				ctx.printf("Fixed: bfReserved%d %v → 0\n", fieldOffset/2-2, v)
+				numFixed++
+			}
+		}
+	}
+
+	// INFOHEADER
+	if ctx.infoHeaderSize >= 24 && !ctx.isCompressed && ctx.calculatedSize > 0 &&
+		int64(ctx.sizeImage) != ctx.calculatedSize {
+		binary.LittleEndian.PutUint32(d[34:38], uint32(ctx.calculatedSize))
+		ctx.printf("Fixed: %s %v → %v\n", translateFieldName(ctx, "SizeImage"),
+			ctx.sizeImage, ctx.calculatedSize)
+		numFixed++
+	}
+
+	// Color table
+	if ctx.palBytesPerEntry == 4 {
+		palOffset := 14 + int64(ctx.infoHeaderSize)
+		if ctx.hasBitfieldsSegment {
+			palOffset += ctx.bitfieldsSegmentSize
+		}
+		numPaddingFixed := 0
+		for i := 0; i < ctx.palNumEntries; i++ {
+			pos := palOffset + int64(i)*4 + 3
+			if pos >= int64(len(d)) {
+				break
+			}
+			if d[pos] != 0 {
+				d[pos] = 0
+				numPaddingFixed++
+			}
+		}
+		if numPaddingFixed > 0 {
+			ctx.printf("Fixed: %v nonzero color table padding bytes → 0\n", numPaddingFixed)
+			numFixed++
+		}
+	}
+
+	if numFixed == 0 {
+		startLineAbsolute(ctx, ctx.fileSize)
+		ctx.print("(Nothing to repair)\n")
+	}
+
+	err := ioutil.WriteFile(outputPath, d, 0644)
+	if err != nil {
+		return err
+	}
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.printf("(Wrote %s)\n", outputPath)
+	return nil
+}
@@ -0,0 +1,81 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/riff.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+
+// Some applications store a BMP image in a RIFF container, in a chunk named
+// "DIBs" or "DIBS". The chunk contains the image without a fileheader: the
+// infoheader, color table, and bits, one after the other.
+
+func isRIFFDIBChunk(id string) bool {
+	return id == "DIBs" || id == "DIBS"
+}
+
+// Inspect the image in a RIFF chunk that starts at pos and has size bytes.
+func readRIFFDIB(parent *ctx_type, pos int64, size int64) error {
+	ctx := newSubImageCtx(parent)
+	ctx.noFileheader = true
+	ctx.pos = pos
+	ctx.fileSize = pos + size
+
+	err := readBmp(ctx)
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.print("----- End of DIB chunk -----\n")
+
+	parent.score = ctx.score
+	return err
+}
+
+// Read a RIFF file, listing its chunks, and inspecting any DIB chunk as a
+// BMP image. ctx.pos is the position of the "RIFF" signature.
+func readRIFFContainer(ctx *ctx_type) error {
+	defer enterSection(ctx, "")()
+	if ctx.fileSize-ctx.pos < 12 {
+		return errors.New("Unexpected end of file")
+	}
+	d := ctx.file.bytes(ctx.pos, ctx.pos+12)
+
+	printSectionBanner(ctx, "RIFF header", 12)
+	ctx.pfxPrintf(0, "Signature", "%+q\n", string(d[0:4]))
+	riffSize := getDWORD(d[4:8])
+	ctx.pfxPrintf(4, "Size", "%v\n", riffSize)
+	ctx.pfxPrintf(8, "Form type", "%+q\n", string(d[8:12]))
+
+	endPos := ctx.pos + 8 + int64(riffSize)
+	if endPos > ctx.fileSize {
+		ctx.warn("header", "RIFF size (%v) exceeds the file size", riffSize)
+		endPos = ctx.fileSize
+	}
+
+	foundDIB := false
+	pos := ctx.pos + 12
+	for pos+8 <= endPos {
+		id := string(ctx.file.bytes(pos, pos+4))
+		size := int64(getDWORD(ctx.file.bytes(pos+4, pos+8)))
+		startLineAbsolute(ctx, pos)
+		ctx.printf("(Chunk %+q, size=%v)\n", id, size)
+
+		dataPos := pos + 8
+		if size > endPos-dataPos {
+			return errors.New("RIFF chunk extends past the end of the file")
+		}
+		if isRIFFDIBChunk(id) {
+			foundDIB = true
+			err := readRIFFDIB(ctx, dataPos, size)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Chunks are padded to an even size.
+		pos = dataPos + size + size%2
+	}
+
+	if !foundDIB {
+		ctx.warn("header", "RIFF file has no DIB chunk")
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/rleanalysis.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// The buckets of the compressed run length histogram, by the largest length
+// in each.
+var rleRunLengthBuckets = [...]struct {
+	max  int
+	name string
+}{
+	{1, "1"}, {5, "2-5"}, {20, "6-20"}, {50, "21-50"}, {100, "51-100"}, {255, "101-255"},
+}
+
+func rleRunLengthBucket(n int) int {
+	for i, b := range rleRunLengthBuckets {
+		if n <= b.max {
+			return i
+		}
+	}
+	return len(rleRunLengthBuckets) - 1
+}
+
+// Print the analysis requested by -rle-analysis. pos is the offset of the
+// end of the compressed data.
+func printRLEAnalysis(ctx *ctx_type, rlectx *rlectx_type, pos int64) {
+	if pos < 1 {
+		return
+	}
+	controlBytesUsed := int(pos) - rlectx.compRunBytesUsed - rlectx.uncRunBytesUsed
+
+	startLine(ctx, pos)
+	ctx.print("----- RLE analysis -----\n")
+
+	for _, item := range []struct {
+		name string
+		n    int
+	}{
+		{"compressed runs", rlectx.compRunBytesUsed},
+		{"uncompressed runs", rlectx.uncRunBytesUsed},
+		{"control codes", controlBytesUsed},
+	} {
+		startLine(ctx, pos)
+		ctx.printf("(Bytes in %s: %v (%.1f%%))\n", item.name, item.n,
+			100*float64(item.n)/float64(pos))
+	}
+
+	startLine(ctx, pos)
+	ctx.print("(Compressed run lengths:")
+	for i, b := range rleRunLengthBuckets {
+		if i > 0 {
+			ctx.print(",")
+		}
+		ctx.printf(" %s: %v", b.name, rlectx.runLengthHist[i])
+	}
+	ctx.print(")\n")
+
+	if rlectx.numUncRuns > 0 {
+		headerBytes := 2 * rlectx.numUncRuns
+		payloadBytes := rlectx.uncRunBytesUsed - headerBytes
+		startLine(ctx, pos)
+		ctx.printf("(Average uncompressed run length: %.1f pixels)\n",
+			float64(rlectx.uncRunPixels)/float64(rlectx.numUncRuns))
+		startLine(ctx, pos)
+		ctx.printf("(Uncompressed run overhead: %v header bytes / %v payload bytes = %.1f%%)\n",
+			headerBytes, payloadBytes, 100*float64(headerBytes)/float64(payloadBytes))
+	}
+
+	startLine(ctx, pos)
+	ctx.printf("(Minimum size with same-color runs merged: %v bytes)\n",
+		int(pos)-rlectx.mergeableBytes)
+}
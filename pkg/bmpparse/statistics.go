@@ -0,0 +1,215 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/statistics.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "math"
+import "sort"
+
+// The number of palette indices listed by -statistics.
+const numTopPaletteIndices = 5
+
+type paletteIndexCount_type struct {
+	index int
+	count int64
+}
+
+type imageStatistics struct {
+	width     int
+	height    int
+	numPixels int64
+	bitCount  int
+
+	// The actual size of the bitmap bits, and the size they would have if
+	// there were no row padding.
+	actualBytes int64
+	minBytes    int64
+
+	colorSpace   string
+	isCompressed bool
+
+	// The remaining fields are only set if hasPixelStats is set.
+	hasPixelStats   bool
+	hasTransparency bool
+	minLum, maxLum  float64
+	dominantValue   uint32
+	dominantCount   int64
+	entropy         float64 // In bits per pixel
+
+	// Images with a palette
+	isIndexed         bool
+	uniqueColors      int
+	paletteSize       int
+	topPaletteIndices []paletteIndexCount_type
+
+	// 24-bit images
+	hasChannelStats bool
+	channelMean     [3]float64 // R, G, B
+	channelStdDev   [3]float64
+}
+
+// Gather statistics about the image. d is the bitmap bits.
+func collectImageStatistics(ctx *ctx_type, d []byte) *imageStatistics {
+	stats := new(imageStatistics)
+	stats.width = ctx.imgWidth
+	stats.height = ctx.imgHeight
+	stats.numPixels = int64(ctx.imgWidth) * int64(ctx.imgHeight)
+	stats.bitCount = ctx.bitCount
+	stats.actualBytes = ctx.actualBitsSize
+	stats.minBytes = (stats.numPixels*int64(ctx.bitCount) + 7) / 8
+	stats.isCompressed = ctx.isCompressed
+	stats.isIndexed = ctx.bitCount >= 1 && ctx.bitCount <= 8
+	stats.paletteSize = ctx.palNumEntries
+
+	masks := getEffectiveMasks(ctx)
+	switch {
+	case stats.isIndexed:
+		stats.colorSpace = "RGB, with palette"
+	case ctx.bitCount == 24:
+		stats.colorSpace = "RGB"
+	case masks[3] != 0:
+		stats.colorSpace = "RGBA"
+	default:
+		stats.colorSpace = "RGB"
+	}
+
+	if ctx.isCompressed || printRowFuncs[ctx.bitCount] == nil || ctx.rowStride < 1 ||
+		int64(len(d)) < ctx.calculatedSize || stats.numPixels < 1 {
+		return stats
+	}
+	stats.hasPixelStats = true
+
+	counts := make(map[uint32]int64)
+	var sum, sumSq [3]float64
+	stats.minLum = 255
+	stats.maxLum = 0
+
+	for y := 0; y < ctx.imgHeight; y++ {
+		row := d[int64(y)*ctx.rowStride : int64(y+1)*ctx.rowStride]
+		for x := 0; x < ctx.imgWidth; x++ {
+			v := getPixelValue(row, x, ctx.bitCount)
+			counts[v]++
+
+			var r, g, b float64
+			if stats.isIndexed || ctx.bitCount == 24 {
+				r8, g8, b8 := getPixelRGB(ctx, masks, v)
+				r, g, b = float64(r8), float64(g8), float64(b8)
+			} else {
+				r = getMaskedChannelFloat(v, masks[0])
+				g = getMaskedChannelFloat(v, masks[1])
+				b = getMaskedChannelFloat(v, masks[2])
+				if masks[3] != 0 && v&masks[3] != masks[3] {
+					stats.hasTransparency = true
+				}
+			}
+
+			lum := 0.299*r + 0.587*g + 0.114*b
+			if lum < stats.minLum {
+				stats.minLum = lum
+			}
+			if lum > stats.maxLum {
+				stats.maxLum = lum
+			}
+
+			if ctx.bitCount == 24 {
+				for i, c := range [3]float64{r, g, b} {
+					sum[i] += c
+					sumSq[i] += c * c
+				}
+			}
+		}
+	}
+
+	// Find the most common pixel value, and the entropy.
+	var indexCounts []paletteIndexCount_type
+	for v, n := range counts {
+		if n > stats.dominantCount || (n == stats.dominantCount && v < stats.dominantValue) {
+			stats.dominantValue = v
+			stats.dominantCount = n
+		}
+		p := float64(n) / float64(stats.numPixels)
+		stats.entropy -= p * math.Log2(p)
+		if stats.isIndexed {
+			indexCounts = append(indexCounts, paletteIndexCount_type{int(v), n})
+		}
+	}
+
+	if stats.isIndexed {
+		stats.uniqueColors = len(counts)
+		sort.Slice(indexCounts, func(i, j int) bool {
+			if indexCounts[i].count != indexCounts[j].count {
+				return indexCounts[i].count > indexCounts[j].count
+			}
+			return indexCounts[i].index < indexCounts[j].index
+		})
+		if len(indexCounts) > numTopPaletteIndices {
+			indexCounts = indexCounts[:numTopPaletteIndices]
+		}
+		stats.topPaletteIndices = indexCounts
+	}
+
+	if ctx.bitCount == 24 {
+		stats.hasChannelStats = true
+		for i := range sum {
+			mean := sum[i] / float64(stats.numPixels)
+			stats.channelMean[i] = mean
+			stats.channelStdDev[i] = math.Sqrt(math.Max(0, sumSq[i]/float64(stats.numPixels)-mean*mean))
+		}
+	}
+
+	return stats
+}
+
+func printImageStatistics(ctx *ctx_type, stats imageStatistics) {
+	ctx.print("----- Statistics -----\n")
+	ctx.printf("Dimensions: %vx%v (area %v)\n", stats.width, stats.height, stats.numPixels)
+	ctx.printf("Pixels: %v\n", stats.numPixels)
+	ctx.printf("Bit depth: %v\n", stats.bitCount)
+	ctx.printf("Color space: %s\n", stats.colorSpace)
+
+	if stats.actualBytes > 0 {
+		ctx.printf("Storage: %v bytes, minimum %v (%.1f%% efficient)\n", stats.actualBytes,
+			stats.minBytes, 100.0*float64(stats.minBytes)/float64(stats.actualBytes))
+		if stats.isCompressed && stats.numPixels > 0 {
+			ctx.printf("Compression ratio: %.2f%%\n",
+				100.0*float64(stats.actualBytes)/float64(ctx.calculatedSize))
+		}
+	}
+
+	if !stats.hasPixelStats {
+		ctx.print("(Pixel statistics are only available for uncompressed images)\n")
+		return
+	}
+
+	if stats.hasTransparency {
+		ctx.print("Transparency: yes\n")
+	} else {
+		ctx.print("Transparency: no\n")
+	}
+	ctx.printf("Luminance: min %.1f, max %.1f\n", stats.minLum, stats.maxLum)
+	ctx.printf("Dominant pixel value: 0x%x (%v pixels, %.1f%%)\n", stats.dominantValue,
+		stats.dominantCount, 100.0*float64(stats.dominantCount)/float64(stats.numPixels))
+	ctx.printf("Entropy: %.3f bits/pixel\n", stats.entropy)
+
+	if stats.isIndexed {
+		ctx.printf("Unique colors used: %v", stats.uniqueColors)
+		if stats.paletteSize > 0 {
+			ctx.printf(" of %v (%.1f%% of palette)", stats.paletteSize,
+				100.0*float64(stats.uniqueColors)/float64(stats.paletteSize))
+		}
+		ctx.print("\n")
+		ctx.print("Most used palette indices:")
+		for _, e := range stats.topPaletteIndices {
+			ctx.printf(" 0x%02x (%v)", e.index, e.count)
+		}
+		ctx.print("\n")
+	}
+
+	if stats.hasChannelStats {
+		for i, name := range [3]string{"Red", "Green", "Blue"} {
+			ctx.printf("%s: mean %.2f, std. dev. %.2f\n", name, stats.channelMean[i],
+				stats.channelStdDev[i])
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/subcommands.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "errors"
+import "flag"
+import "fmt"
+import "os"
+import "io/ioutil"
+
+// Each subcommand parses its own arguments (not including the subcommand
+// name) with its own flag.FlagSet.
+type subcommand_type interface {
+	run(args []string) error
+}
+
+type inspectCmd_type struct{}
+type validateCmd_type struct{}
+type dumpCmd_type struct{}
+type compareCmd_type struct{}
+type createCmd_type struct{}
+
+var subcommands = map[string]subcommand_type{
+	"inspect":  inspectCmd_type{},
+	"validate": validateCmd_type{},
+	"dump":     dumpCmd_type{},
+	"compare":  compareCmd_type{},
+	"create":   createCmd_type{},
+}
+
+// Make a new ctx, and open fileName for it. The caller must call
+// ctx.file.close.
+func newFileCtx(fileName string) (*ctx_type, error) {
+	var err error
+
+	ctx := new(ctx_type)
+	ctx.fileName = fileName
+	// The RLE decoder only runs if printPixels is set.
+	ctx.printPixels = true
+	ctx.compressionType = "none"
+
+	err = loadBmpFile(ctx, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Read fileName, and parse it without printing anything. Unless there is an
+// error, the caller must call ctx.file.close.
+func readBmpQuietly(fileName string) (*ctx_type, error) {
+	ctx, err := newFileCtx(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.suppressOutput = true
+	err = readBmp(ctx)
+	ctx.suppressOutput = false
+	if err != nil {
+		ctx.file.close()
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// "inspect": The normal behavior. This is the default subcommand.
+func (inspectCmd_type) run(args []string) error {
+	ctx := new(ctx_type)
+	return main2(ctx, args)
+}
+
+// "validate": Print only the warnings and the validity score. The exit
+// status is 0 for PASS, 1 for WARN, and 2 for FAIL.
+func (validateCmd_type) run(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return errors.New("Usage error")
+	}
+
+	ctx, err := newFileCtx(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer ctx.file.close()
+
+	ctx.suppressOutput = true
+	ctx.alwaysShowWarnings = true
+	err = readBmp(ctx)
+	ctx.suppressOutput = false
+	if err != nil {
+		ctx.score.deduct("error")
+		ctx.printf("Error: %v\n", err.Error())
+	}
+	printValidityScore(ctx)
+
+	switch validityResult(ctx.score.score()) {
+	case "WARN":
+		os.Exit(1)
+	case "FAIL":
+		os.Exit(2)
+	}
+	return nil
+}
+
+// "create": Create a new BMP file.
+func (createCmd_type) run(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	width := fs.Int("width", 0, "Image width")
+	height := fs.Int("height", 0, "Image height")
+	bitCount := fs.Int("bpp", 24, "Bits per pixel: 1, 4, 8, 16, 24, or 32")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return errors.New("Usage error")
+	}
+	return createBMP(*width, *height, *bitCount, fs.Arg(0))
+}
+
+// "dump": Write the pixels to a file (or stdout) as 8-bit RGB samples, with
+// or without a PPM header.
+func (dumpCmd_type) run(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	format := fs.String("format", "ppm", "Output format: raw or ppm")
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return errors.New("Usage error")
+	}
+	if *format != "raw" && *format != "ppm" {
+		return errors.New("Unknown format")
+	}
+
+	ctx, err := readBmpQuietly(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer ctx.file.close()
+	pix, err := decodeImageRGB(ctx)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if *format == "ppm" {
+		out = []byte(fmt.Sprintf("P6\n%d %d\n255\n", ctx.imgWidth, ctx.imgHeight))
+	}
+	out = append(out, pix...)
+
+	if fs.NArg() < 2 {
+		_, err = ctx.writer().Write(out)
+		return err
+	}
+	return ioutil.WriteFile(fs.Arg(1), out, 0666)
+}
@@ -0,0 +1,130 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/table.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "fmt"
+import "io"
+import "regexp"
+import "strings"
+import "unicode/utf8"
+
+// With -table-headers, the lines printed for the fileheader and infoheader
+// are collected into a two-column table, which is printed at the end of the
+// section.
+type tableFormatter struct {
+	unicode bool // Use box-drawing characters, instead of ASCII
+	rows    [][2]string
+	pending string // The part of the current line printed so far
+}
+
+// The offset that startLine puts at the beginning of each line.
+var tableOffsetPrefix = regexp.MustCompile(`^ *[0-9]+: `)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func (t *tableFormatter) addRow(field, value string) {
+	t.rows = append(t.rows, [2]string{field, value})
+}
+
+// Collect printed text. Each complete line becomes a row. Lines that look
+// like "name: value" are split into the two columns. Other lines, such as
+// notes and warnings, go in the Value column.
+func (t *tableFormatter) capture(s string) {
+	t.pending += s
+	for {
+		i := strings.IndexByte(t.pending, '\n')
+		if i < 0 {
+			return
+		}
+		t.captureLine(t.pending[:i])
+		t.pending = t.pending[i+1:]
+	}
+}
+
+func (t *tableFormatter) captureLine(line string) {
+	line = tableOffsetPrefix.ReplaceAllString(line, "")
+	if !strings.HasPrefix(line, "(") && !strings.HasPrefix(line, "Warning:") {
+		if i := strings.Index(line, ": "); i > 0 {
+			t.addRow(line[:i], strings.TrimSpace(line[i+2:]))
+			return
+		}
+	}
+	t.addRow("", line)
+}
+
+// The number of columns s occupies on the screen.
+func tableTextWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+func (t *tableFormatter) print(out io.Writer) {
+	if t.pending != "" {
+		t.captureLine(t.pending)
+		t.pending = ""
+	}
+	header := [2]string{"Field", "Value"}
+	var widths [2]int
+	for _, row := range append([][2]string{header}, t.rows...) {
+		for c := range row {
+			if w := tableTextWidth(row[c]); w > widths[c] {
+				widths[c] = w
+			}
+		}
+	}
+
+	// The characters for the top, middle, and bottom borders: left, fill,
+	// column separator, right.
+	borders := [3]string{"+-++", "+-++", "+-++"}
+	vert := "|"
+	if t.unicode {
+		borders = [3]string{"┌─┬┐", "├─┼┤", "└─┴┘"}
+		vert = "│"
+	}
+	border := func(b string) {
+		r := []rune(b)
+		fmt.Fprintf(out, "%c%s%c%s%c\n", r[0],
+			strings.Repeat(string(r[1]), widths[0]+2), r[2],
+			strings.Repeat(string(r[1]), widths[1]+2), r[3])
+	}
+	row := func(cells [2]string) {
+		fmt.Fprint(out, vert)
+		for c := range cells {
+			fmt.Fprintf(out, " %s%s %s", cells[c],
+				strings.Repeat(" ", widths[c]-tableTextWidth(cells[c])), vert)
+		}
+		fmt.Fprint(out, "\n")
+	}
+
+	border(borders[0])
+	row(header)
+	border(borders[1])
+	for _, r := range t.rows {
+		row(r)
+	}
+	border(borders[2])
+}
+
+// Called at the start of a header section, after its banner. If
+// -table-headers is in effect, the section's output is collected into a
+// table, which is printed when the returned function is called.
+// Usage: defer beginHeaderTable(ctx)()
+func beginHeaderTable(ctx *ctx_type) func() {
+	if !ctx.tableHeaders {
+		return func() {}
+	}
+	t := &tableFormatter{unicode: ctx.tableUnicode}
+	ctx.table = t
+	return func() {
+		ctx.table = nil
+		if len(t.rows) == 0 && t.pending == "" {
+			// Nothing was printed, e.g. because of -section.
+			return
+		}
+		var buf bytes.Buffer
+		t.print(&buf)
+		ctx.print(buf.String())
+	}
+}
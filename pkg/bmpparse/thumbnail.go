@@ -0,0 +1,73 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/thumbnail.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Some BMP files are said to store the position of a small thumbnail image
+// in the bfReserved1 and bfReserved2 fields, taken together as a 32-bit
+// value. The thumbnail has an infoheader but no fileheader. This is not
+// documented anywhere, so the detection is heuristic.
+
+// Infoheader sizes we'll accept for a thumbnail.
+var thumbnailInfoHeaderSizes = map[uint32]bool{
+	12: true, 40: true, 52: true, 56: true, 64: true, 108: true, 124: true,
+}
+
+// Decide whether the combined bfReserved fields could be the position of a
+// thumbnail image. If so, sets ctx.thumbnailPos.
+func detectThumbnail(ctx *ctx_type, combined uint32) {
+	pos := int64(combined)
+	if pos < 14+int64(ctx.infoHeaderSize) || pos > ctx.fileSize-18 {
+		return
+	}
+	if !thumbnailInfoHeaderSizes[getDWORD(ctx.file.bytes(pos, pos+4))] {
+		return
+	}
+	if getWORD(ctx.file.bytes(pos+4, pos+6)) == 0 {
+		return
+	}
+
+	ctx.thumbnailPos = pos
+	startLine(ctx, 6)
+	ctx.printf("(Possible embedded thumbnail at offset %v)\n", pos)
+}
+
+// Make a new ctx for inspecting an image embedded in the image described by
+// parent. Only the data, the user's options, the output writer, and the
+// validity score are copied.
+func newSubImageCtx(parent *ctx_type) *ctx_type {
+	ctx := new(ctx_type)
+	ctx.options_type = parent.options_type
+	ctx.fileName = parent.fileName
+	ctx.file = parent.file
+	ctx.fileSize = parent.fileSize
+	ctx.printPixels = parent.printPixels
+	ctx.score = parent.score
+	ctx.suppressOutput = parent.suppressOutput
+	ctx.out = parent.out
+	ctx.recorder = parent.recorder
+	ctx.compressionType = "none"
+	return ctx
+}
+
+// Inspect the thumbnail found by detectThumbnail.
+func readThumbnail(parent *ctx_type) error {
+	ctx := newSubImageCtx(parent)
+	ctx.noFileheader = true
+	ctx.pos = parent.thumbnailPos
+	// If the thumbnail is before the main image's bits, it must end there.
+	if ctx.pos < int64(parent.bfOffBits) {
+		ctx.fileSize = int64(parent.bfOffBits)
+	}
+
+	startLine(ctx, 0)
+	ctx.print("----- Thumbnail -----\n")
+	err := readBmp(ctx)
+	startLineAbsolute(ctx, ctx.fileSize)
+	ctx.print("----- End of thumbnail -----\n")
+
+	// Problems with the thumbnail count against the file's validity score.
+	parent.score = ctx.score
+	return err
+}
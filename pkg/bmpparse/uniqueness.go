@@ -0,0 +1,71 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/uniqueness.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+// Images with more pixels than this are sampled by -pixel-uniqueness.
+const uniquenessSampleThreshold = 1000000
+
+// With sampling, every Nth pixel (in file order) is measured.
+const uniquenessSampleInterval = 8
+
+type uniqueness_type struct {
+	numPixels int64 // The number of pixels measured
+	numUnique int64 // Pixels whose value appears only once
+	sampled   bool
+}
+
+// Count the pixels of an uncompressed image whose value appears only once.
+// As with -complexity, images with a palette are measured by their palette
+// indices, and other images by their RGB colors.
+func computeUniqueness(ctx *ctx_type, d []byte) uniqueness_type {
+	var u uniqueness_type
+	isIndexed := ctx.bitCount <= 8
+	masks := getEffectiveMasks(ctx)
+	u.sampled = int64(ctx.imgWidth)*int64(ctx.imgHeight) > uniquenessSampleThreshold
+
+	freq := make(map[uint32]int64)
+	var n int64
+	for y := 0; y < ctx.imgHeight; y++ {
+		row := d[int64(y)*ctx.rowStride : int64(y+1)*ctx.rowStride]
+		for x := 0; x < ctx.imgWidth; x++ {
+			n++
+			if u.sampled && n%uniquenessSampleInterval != 1 {
+				continue
+			}
+			v := getPixelValue(row, x, ctx.bitCount)
+			if !isIndexed {
+				r, g, b := getPixelRGB(ctx, masks, v)
+				v = uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+			}
+			freq[v]++
+			u.numPixels++
+		}
+	}
+
+	for _, count := range freq {
+		if count == 1 {
+			u.numUnique++
+		}
+	}
+	return u
+}
+
+func reportPixelUniqueness(ctx *ctx_type, d []byte) {
+	if ctx.imgWidth < 1 || ctx.imgHeight < 1 || printRowFuncs[ctx.bitCount] == nil {
+		return
+	}
+	u := computeUniqueness(ctx, d)
+	numRepeated := u.numPixels - u.numUnique
+
+	startLine(ctx, 0)
+	ctx.printf("(Unique colors: %v of %v total pixels = %.1f%%; Repeated pixels: %v of %v = %.1f%%)\n",
+		u.numUnique, u.numPixels, 100*float64(u.numUnique)/float64(u.numPixels),
+		numRepeated, u.numPixels, 100*float64(numRepeated)/float64(u.numPixels))
+	if u.sampled {
+		startLine(ctx, 0)
+		ctx.printf("(Uniqueness measured on every %vth pixel, due to the image size)\n",
+			uniquenessSampleInterval)
+	}
+}
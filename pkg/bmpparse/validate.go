@@ -0,0 +1,92 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/validate.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "strings"
+import "fmt"
+
+type validityCategory_type struct {
+	name   string
+	points int // Points deducted for each problem
+	max    int // Maximum total deduction
+}
+
+// The categories of problems that affect the validity score, in the order
+// they are reported.
+var validityCategories = []validityCategory_type{
+	{"bfSize", 5, 5},
+	{"reserved", 10, 10},
+	{"header", 5, 20},
+	{"dimensions", 20, 40},
+	{"bitCount", 20, 20},
+	{"compression", 20, 20},
+	{"bitfields", 10, 20},
+	{"palette", 10, 30},
+	{"pixels", 40, 40},
+	{"error", 50, 50}, // The file could not be fully inspected
+}
+
+// A score from 0 to 100, indicating how valid a BMP file is.
+type validityScore struct {
+	total       int
+	deductions  map[string]int
+	numProblems int
+}
+
+// Record a problem in the given category.
+func (vs *validityScore) deduct(category string) {
+	if vs.deductions == nil {
+		vs.deductions = make(map[string]int)
+	}
+	vs.numProblems++
+	for _, c := range validityCategories {
+		if c.name != category {
+			continue
+		}
+		n := c.points
+		if vs.deductions[category]+n > c.max {
+			n = c.max - vs.deductions[category]
+		}
+		vs.deductions[category] += n
+		vs.total += n
+		return
+	}
+}
+
+func (vs *validityScore) score() int {
+	if vs.total > 100 {
+		return 0
+	}
+	return 100 - vs.total
+}
+
+func printValidityScore(ctx *ctx_type) {
+	var items []string
+
+	for _, c := range validityCategories {
+		if ctx.score.deductions[c.name] > 0 {
+			items = append(items, fmt.Sprintf("-%d %s", ctx.score.deductions[c.name], c.name))
+		}
+	}
+
+	score := ctx.score.score()
+	ctx.printf("Validity score: %d/100 (%d warnings", score, ctx.score.numProblems)
+	if len(items) > 0 {
+		ctx.print(": " + strings.Join(items, ", "))
+	}
+	ctx.print(")\n")
+
+	ctx.printf("Validation result: %s\n", validityResult(score))
+}
+
+// Return "PASS", "WARN", or "FAIL", depending on the validity score.
+func validityResult(score int) string {
+	if score >= 90 {
+		return "PASS"
+	} else if score >= 70 {
+		return "WARN"
+	}
+	return "FAIL"
+}
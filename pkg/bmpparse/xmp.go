@@ -0,0 +1,115 @@
+// ◄◄◄ bmpinspect/pkg/bmpparse/xmp.go ►►►
+//
+// Copyright © 2012–2018 Jason Summers
+
+package bmpparse
+
+import "bytes"
+import "strings"
+
+// XMP properties to display, if present.
+var xmpPropertyNames = []string{
+	"dc:creator",
+	"dc:rights",
+	"xmp:CreateDate",
+	"xmp:ModifyDate",
+	"photoshop:DateCreated",
+	"tiff:ImageWidth",
+	"tiff:ImageHeight",
+}
+
+// Remove XML tags from s, and collapse the whitespace.
+func stripXMLTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			inTag = true
+			b.WriteRune(' ')
+		case c == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Find the value of an XMP property, which may be written either as an
+// attribute (name="value") or as an element (<name>value</name>). The value
+// of an element may be nested in other elements, such as rdf:Seq. This is a
+// simple string search, not a real XML parser.
+func findXMPProperty(xmp string, name string) (string, bool) {
+	i := strings.Index(xmp, name+"=\"")
+	if i >= 0 {
+		v := xmp[i+len(name)+2:]
+		j := strings.IndexByte(v, '"')
+		if j >= 0 {
+			return v[:j], true
+		}
+	}
+
+	i = strings.Index(xmp, "<"+name+">")
+	if i >= 0 {
+		v := xmp[i+len(name)+2:]
+		j := strings.Index(v, "</"+name+">")
+		if j >= 0 {
+			return stripXMLTags(v[:j]), true
+		}
+	}
+	return "", false
+}
+
+// Look for an XMP packet in d, which starts at file position pos, and print
+// some information about it.
+func inspectXMP(ctx *ctx_type, pos int64, d []byte) {
+	i := bytes.Index(d, []byte("<?xpacket begin"))
+	if i < 0 {
+		startLineAbsolute(ctx, pos)
+		ctx.print("(No XMP metadata found)\n")
+		return
+	}
+	d = d[i:]
+	pos += int64(i)
+
+	// The packet ends after the "<?xpacket end=...?>" processing instruction.
+	packetLen := len(d)
+	j := bytes.Index(d, []byte("<?xpacket end"))
+	if j >= 0 {
+		k := bytes.Index(d[j:], []byte("?>"))
+		if k >= 0 {
+			packetLen = j + k + 2
+		}
+	}
+	xmp := string(d[:packetLen])
+
+	startLineAbsolute(ctx, pos)
+	ctx.print("----- XMP metadata -----\n")
+	startLineAbsolute(ctx, pos)
+	ctx.printf("(Packet length: %v)\n", packetLen)
+	if j < 0 {
+		ctx.warn("header", "XMP packet has no end marker")
+	}
+
+	header := xmp
+	k := strings.Index(header, "?>")
+	if k >= 0 {
+		header = header[:k+2]
+	}
+	startLineAbsolute(ctx, pos)
+	ctx.print("Header: \"")
+	printWindows1252String(ctx, []byte(header))
+	ctx.print("\"\n")
+
+	for _, name := range xmpPropertyNames {
+		v, ok := findXMPProperty(xmp, name)
+		if !ok {
+			continue
+		}
+		startLineAbsolute(ctx, pos)
+		ctx.printf("%s: \"", name)
+		printWindows1252String(ctx, []byte(v))
+		ctx.print("\"\n")
+	}
+}